@@ -0,0 +1,81 @@
+// Package cli 配置相关的命令行工具，业务可在自己的 main 中调用 Run 以使用注册的配置类型
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Command 子命令
+type Command struct {
+	Name  string
+	Usage string
+	Run   func(args []string, stdout io.Writer) error
+}
+
+var commands = map[string]*Command{}
+
+// RegisterCommand 注册子命令
+func RegisterCommand(cmd *Command) {
+	commands[cmd.Name] = cmd
+}
+
+// Run 执行子命令，args 不包含程序名
+func Run(args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		usage(stdout)
+		return nil
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		usage(stdout)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	return cmd.Run(args[1:], stdout)
+}
+
+// Main 供 main 函数直接调用，出错时以非零状态退出
+func Main() {
+	if err := Run(os.Args[1:], os.Stdout); err != nil && !errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func usage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "Usage: conf <command> [flags] [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].Usage)
+	}
+}
+
+// newFlagSet 创建子命令的参数解析器
+func newFlagSet(name string, stdout io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	return fs
+}
+
+// readValue 读取待处理的值，参数为 "-" 或为空时从标准输入读取
+func readValue(args []string) (string, error) {
+	if len(args) > 0 && args[0] != "-" {
+		return args[0], nil
+	}
+	b, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
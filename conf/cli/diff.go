@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/xybingbing/pkg/conf"
+)
+
+func init() {
+	RegisterCommand(&Command{Name: "diff", Usage: "比较两个配置文件或两个 profile 最终生效的值", Run: diff})
+}
+
+// diffResult diff 的 JSON 输出
+type diffResult struct {
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	Changes []conf.Change `json:"changes"`
+}
+
+func diff(args []string, stdout io.Writer) error {
+	fs := newFlagSet("diff", stdout)
+	typ := fs.String("type", "", "已通过 cli.Register 注册的配置类型")
+	profile := fs.String("profile", "", "比较两个文件时使用的 profile")
+	profiles := fs.String("profiles", "", "比较同一文件的两个 profile，如 staging,prod")
+	confDir := fs.String("confdir", "", "额外合并的配置片段目录")
+	asJSON := fs.Bool("json", false, "以 JSON 输出结果")
+	exitCode := fs.Bool("exit-code", false, "存在差异时返回非零状态")
+	fs.Usage = func() {
+		fmt.Fprintln(stdout, "Usage: conf diff -type name [flags] a.yaml b.yaml")
+		fmt.Fprintln(stdout, "       conf diff -type name -profiles a,b [flags] file")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	from, to, err := diffTargets(fs, *profile, *profiles)
+	if err != nil {
+		return err
+	}
+	a, err := loadConfig(*typ, from.file, from.profile, *confDir)
+	if err != nil {
+		return fmt.Errorf("%s: %w", from, err)
+	}
+	b, err := loadConfig(*typ, to.file, to.profile, *confDir)
+	if err != nil {
+		return fmt.Errorf("%s: %w", to, err)
+	}
+	changes, err := conf.Diff(a, b)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(diffResult{From: from.String(), To: to.String(), Changes: changes}); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(stdout, "--- %s\n+++ %s\n", from, to)
+		for _, c := range changes {
+			switch c.Op {
+			case "added":
+				fmt.Fprintf(stdout, "+ %s = %v\n", c.Key, c.New)
+			case "removed":
+				fmt.Fprintf(stdout, "- %s = %v\n", c.Key, c.Old)
+			default:
+				fmt.Fprintf(stdout, "~ %s: %v -> %v\n", c.Key, c.Old, c.New)
+			}
+		}
+	}
+	if *exitCode && len(changes) > 0 {
+		return fmt.Errorf("%d keys differ", len(changes))
+	}
+	return nil
+}
+
+// diffTarget 参与比较的一份配置
+type diffTarget struct {
+	file    string
+	profile string
+}
+
+func (t diffTarget) String() string {
+	if t.profile == "" {
+		return t.file
+	}
+	return t.file + "@" + t.profile
+}
+
+// diffTargets 根据参数确定比较的两份配置
+func diffTargets(fs *flag.FlagSet, profile, profiles string) (diffTarget, diffTarget, error) {
+	if profiles != "" {
+		a, b, ok := strings.Cut(profiles, ",")
+		if !ok || a == "" || b == "" || fs.NArg() != 1 {
+			fs.Usage()
+			return diffTarget{}, diffTarget{}, errors.New("-profiles requires two profiles and exactly one file")
+		}
+		return diffTarget{fs.Arg(0), a}, diffTarget{fs.Arg(0), b}, nil
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return diffTarget{}, diffTarget{}, errors.New("diff requires exactly two files")
+	}
+	return diffTarget{fs.Arg(0), profile}, diffTarget{fs.Arg(1), profile}, nil
+}
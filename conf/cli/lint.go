@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/xybingbing/pkg/conf"
+)
+
+func init() {
+	RegisterCommand(&Command{Name: "lint", Usage: "按已注册的配置类型检查配置文件（默认值、校验规则、未知 key）", Run: lint})
+}
+
+// lintResult lint 的 JSON 输出
+type lintResult struct {
+	File   string   `json:"file"`
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+func lint(args []string, stdout io.Writer) error {
+	fs := newFlagSet("lint", stdout)
+	typ := fs.String("type", "", "已通过 cli.Register 注册的配置类型")
+	profile := fs.String("profile", "", "使用的 profile，默认取环境变量 "+conf.EnvProfile)
+	confDir := fs.String("confdir", "", "额外合并的配置片段目录")
+	asJSON := fs.Bool("json", false, "以 JSON 输出结果")
+	fs.Usage = func() {
+		fmt.Fprintln(stdout, "Usage: conf lint -type name [-profile p] [-confdir dir] [-json] file...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("lint requires at least one file")
+	}
+	var results []lintResult
+	failed := 0
+	for _, file := range fs.Args() {
+		result := lintResult{File: file, Valid: true}
+		if _, err := loadConfig(*typ, file, *profile, *confDir); err != nil {
+			result.Valid = false
+			result.Errors = splitErrors(err)
+			failed++
+		}
+		results = append(results, result)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		for _, result := range results {
+			if result.Valid {
+				fmt.Fprintf(stdout, "%s: ok\n", result.File)
+				continue
+			}
+			for _, msg := range result.Errors {
+				fmt.Fprintf(stdout, "%s: %s\n", result.File, msg)
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed", failed, len(results))
+	}
+	return nil
+}
+
+// loadConfig 以严格模式、schema 和 validate 标签校验加载配置文件到已注册类型的新实例，
+// 命令行指定的文件优先，忽略环境变量 CONF_PATH
+func loadConfig(typ, file, profile, confDir string) (any, error) {
+	v, err := newConfig(typ)
+	if err != nil {
+		return nil, err
+	}
+	opts := []conf.Option{conf.WithStrict(), conf.WithSchemaValidation(), conf.WithValidate(), conf.WithoutEnvPath()}
+	if profile != "" {
+		opts = append(opts, conf.WithProfile(profile))
+	}
+	if confDir != "" {
+		opts = append(opts, conf.WithConfDir(confDir))
+	}
+	if _, err = conf.Load(file, v, opts...); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// splitErrors 展开 errors.Join 合并的错误
+func splitErrors(err error) []string {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var msgs []string
+		for _, e := range joined.Unwrap() {
+			msgs = append(msgs, splitErrors(e)...)
+		}
+		return msgs
+	}
+	return []string{err.Error()}
+}
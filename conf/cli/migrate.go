@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/xybingbing/pkg/conf"
+)
+
+func init() {
+	RegisterCommand(&Command{Name: "migrate", Usage: "将配置文件升级到最新版本并输出变更报告", Run: migrate})
+}
+
+func migrate(args []string, stdout io.Writer) error {
+	fs := newFlagSet("migrate", stdout)
+	write := fs.Bool("w", false, "将结果写回配置文件")
+	asJSON := fs.Bool("json", false, "以 JSON 输出报告")
+	fs.Usage = func() {
+		fmt.Fprintln(stdout, "Usage: conf migrate [-w] [-json] file")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("migrate requires exactly one file")
+	}
+	report, err := conf.MigrateFile(fs.Arg(0), *write)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	if len(report.Applied) == 0 {
+		_, err = fmt.Fprintf(stdout, "%s is already at version %d\n", fs.Arg(0), report.To)
+		return err
+	}
+	fmt.Fprintf(stdout, "version %d -> %d\n", report.From, report.To)
+	for _, applied := range report.Applied {
+		fmt.Fprintf(stdout, "  applied %s\n", applied)
+	}
+	for _, c := range report.Changes {
+		switch c.Op {
+		case "added":
+			fmt.Fprintf(stdout, "  + %s = %v\n", c.Key, c.New)
+		case "removed":
+			fmt.Fprintf(stdout, "  - %s = %v\n", c.Key, c.Old)
+		default:
+			fmt.Fprintf(stdout, "  ~ %s: %v -> %v\n", c.Key, c.Old, c.New)
+		}
+	}
+	if *write {
+		_, err = fmt.Fprintf(stdout, "wrote %s\n", fs.Arg(0))
+	}
+	return err
+}
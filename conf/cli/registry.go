@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var types = map[string]reflect.Type{}
+
+// Register 注册配置结构体类型，name 用于命令行的 -type 参数
+func Register(name string, v any) {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	types[name] = t
+}
+
+// newConfig 创建已注册类型的新实例，返回指针
+func newConfig(name string) (any, error) {
+	t, ok := types[name]
+	if !ok {
+		names := make([]string, 0, len(types))
+		for n := range types {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown config type %q, registered: [%s]", name, strings.Join(names, " "))
+	}
+	return reflect.New(t).Interface(), nil
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/xybingbing/pkg/conf"
+)
+
+func init() {
+	RegisterCommand(&Command{Name: "schema", Usage: "输出已注册配置类型的 JSON Schema", Run: schema})
+	RegisterCommand(&Command{Name: "doc", Usage: "输出已注册配置类型的 Markdown 参考文档", Run: doc})
+}
+
+func schema(args []string, stdout io.Writer) error {
+	v, err := parseType("schema", args, stdout)
+	if err != nil {
+		return err
+	}
+	b, err := conf.JSONSchema(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, string(b))
+	return err
+}
+
+func doc(args []string, stdout io.Writer) error {
+	v, err := parseType("doc", args, stdout)
+	if err != nil {
+		return err
+	}
+	b, err := conf.Markdown(v)
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(b)
+	return err
+}
+
+// parseType 解析只有 -type 参数的子命令
+func parseType(name string, args []string, stdout io.Writer) (any, error) {
+	fs := newFlagSet(name, stdout)
+	typ := fs.String("type", "", "已通过 cli.Register 注册的配置类型")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return newConfig(*typ)
+}
@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/xybingbing/pkg/conf"
+)
+
+func init() {
+	RegisterCommand(&Command{Name: "encrypt", Usage: "加密配置值，输出可写入配置文件的 enc: 值", Run: encrypt})
+	RegisterCommand(&Command{Name: "decrypt", Usage: "解密 enc: 配置值", Run: decrypt})
+}
+
+func encrypt(args []string, stdout io.Writer) error {
+	key, rest, err := parseKey("encrypt", args, stdout)
+	if err != nil {
+		return err
+	}
+	value, err := readValue(rest)
+	if err != nil {
+		return err
+	}
+	enc, err := conf.Encrypt(key, value)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, enc)
+	return err
+}
+
+func decrypt(args []string, stdout io.Writer) error {
+	key, rest, err := parseKey("decrypt", args, stdout)
+	if err != nil {
+		return err
+	}
+	value, err := readValue(rest)
+	if err != nil {
+		return err
+	}
+	plain, err := conf.Decrypt(key, value)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, plain)
+	return err
+}
+
+// parseKey 解析 -key-file 参数，未指定时按 conf.SecretKey 的规则读取环境变量
+func parseKey(name string, args []string, stdout io.Writer) ([]byte, []string, error) {
+	fs := newFlagSet(name, stdout)
+	keyFile := fs.String("key-file", "", "密钥文件路径，默认读取 "+conf.EnvSecretKey+" 或 "+conf.EnvSecretKeyFile)
+	fs.Usage = func() {
+		fmt.Fprintf(stdout, "Usage: conf %s [-key-file path] [value|-]\n", name)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	if *keyFile == "" {
+		key, err := conf.SecretKey()
+		return key, fs.Args(), err
+	}
+	b, err := os.ReadFile(*keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := conf.ParseSecretKey(string(b))
+	return key, fs.Args(), err
+}
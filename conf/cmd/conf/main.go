@@ -0,0 +1,7 @@
+package main
+
+import "github.com/xybingbing/pkg/conf/cli"
+
+func main() {
+	cli.Main()
+}
@@ -1,153 +1,331 @@
 package conf
 
 import (
+	"encoding"
 	"errors"
+	"fmt"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
-	"os"
+	"math"
+	"net/url"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 )
 
-func Load(path string, v any) (*viper.Viper, error) {
-	cfg, err := configFile(path)
-	if err != nil {
+// Load 从配置文件加载配置，环境变量 CONF_PATH 优先于 path（WithoutEnvPath 时除外）
+func Load(path string, v any, opts ...Option) (*viper.Viper, error) {
+	if !newOptions(opts).noEnvPath {
+		path = configPath(path)
+	}
+	return load(fileSource(path), v, opts...)
+}
+
+// load 加载配置的统一流程，所有配置来源都经过相同的默认值、环境变量、密钥解析和校验
+func load(src source, v any, opts ...Option) (*viper.Viper, error) {
+	o := newOptions(opts)
+	//命令行参数先解析，--profile 决定读取哪些配置
+	var fs *pflag.FlagSet
+	var err error
+	if o.flags {
+		if fs, err = parseFlags(v, o); err != nil {
+			return nil, err
+		}
+	}
+	profile := resolveProfile(o, fs)
+	cfg := viper.New()
+	if err = src(cfg, profile); err != nil {
+		return nil, err
+	}
+	//合并配置片段目录
+	if o.confDir != "" {
+		fragments, err := osFiles.readFragments(o.confDir)
+		if err != nil {
+			return nil, err
+		}
+		if err = cfg.MergeConfigMap(fragments); err != nil {
+			return nil, err
+		}
+	}
+	//按 profile 覆盖
+	if cfg, err = applyProfile(cfg, profile); err != nil {
+		return nil, err
+	}
+	//版本迁移
+	if cfg, err = migrateConfig(cfg); err != nil {
 		return nil, err
 	}
+	cfg.AutomaticEnv()
+	bindEnv(cfg, v)
+	//命令行参数
+	var flags map[string]bool
+	if fs != nil {
+		if flags, err = bindFlags(cfg, fs); err != nil {
+			return nil, err
+		}
+	}
+	//迁移废弃的 key
+	migrated := migrateDeprecated(cfg, v)
+	//严格模式
+	if o.strict {
+		if err = checkStrict(cfg, v, append(migrated, versionKey)); err != nil {
+			return nil, err
+		}
+	}
+	//解析密钥引用与加密值
+	if err = resolveSecrets(cfg); err != nil {
+		return nil, err
+	}
+	//按 schema 校验
+	if o.schema {
+		if err = validateSchema(cfg, v); err != nil {
+			return nil, err
+		}
+	}
 	//设置默认值
-	if err = setDefault(v); err != nil {
+	if err = setDefault(cfg, v); err != nil {
 		return nil, err
 	}
 	//解析
-	if err = cfg.Unmarshal(v); err != nil {
+	if err = cfg.Unmarshal(v, viper.DecodeHook(decodeHook())); err != nil {
 		return nil, err
 	}
+	//按 validate 标签校验
+	if o.validate {
+		if err = Validate(v); err != nil {
+			return nil, err
+		}
+	}
+	if o.sources != nil {
+		*o.sources = provenance(cfg, v, flags)
+	}
 	return cfg, err
 }
 
-func configFile(path string) (*viper.Viper, error) {
-	envConf := os.Getenv("CONF_PATH")
-	if envConf == "" {
-		envConf = path
-	}
-
-	conf := viper.New()
-	conf.SetConfigFile(envConf)
-	conf.AutomaticEnv()
-	err := conf.ReadInConfig()
-	return conf, err
+// bindEnv 为结构体的每个字段绑定环境变量，AutomaticEnv 只作用于配置中已有的 key，
+// 绑定后只有默认值的字段也能被环境变量覆盖
+func bindEnv(cfg *viper.Viper, v any) {
+	walkFields(reflect.TypeOf(v), func(f field) bool {
+		if !f.Nested {
+			_ = cfg.BindEnv(f.Key)
+		}
+		return true
+	})
 }
 
-// SetDefault 设置默认值
-func setDefault(ptr interface{}) error {
+// SetDefault 设置默认值，切片只在配置中没有对应 key 时设置，
+// 否则 Unmarshal 会复用默认值的切片，配置中的元素比默认值少时会残留默认值
+func setDefault(cfg *viper.Viper, ptr interface{}) error {
 	if reflect.TypeOf(ptr).Kind() != reflect.Ptr {
 		return errors.New("not a pointer")
 	}
 	v := reflect.ValueOf(ptr).Elem()
-	t := v.Type()
-	if t.Kind() != reflect.Struct {
+	if v.Kind() != reflect.Struct {
 		return nil
 	}
+	return setStructDefault(cfg, v, v.Type().Name(), "")
+}
+
+// setStructDefault 递归设置结构体字段的默认值，path 用于错误信息，key 为结构体对应的 viper key
+func setStructDefault(cfg *viper.Viper, v reflect.Value, path, key string) error {
+	t := v.Type()
 	for i := 0; i < t.NumField(); i++ {
-		if defaultVal := t.Field(i).Tag.Get("default"); defaultVal != "-" && defaultVal != "" {
-			if err := setField(v.Field(i), defaultVal); err != nil {
+		sf := t.Field(i)
+		field := v.Field(i)
+		defaultVal := sf.Tag.Get("default")
+		if !field.CanSet() || defaultVal == "-" {
+			continue
+		}
+		fieldPath := path + "." + sf.Name
+		fieldKey := key
+		if name, squash := keyName(sf); !squash {
+			fieldKey = strings.TrimPrefix(key+"."+name, ".")
+		}
+		if isNested(sf.Type) {
+			if err := setNestedDefault(cfg, field, fieldPath, fieldKey); err != nil {
 				return err
 			}
+			continue
+		}
+		if defaultVal == "" || !isInitialValue(field) {
+			continue
+		}
+		if isSlice(sf.Type) && cfg.IsSet(fieldKey) {
+			continue
+		}
+		if err := setField(field, defaultVal); err != nil {
+			return fmt.Errorf("conf: invalid default %q for %s (%s): %w", defaultVal, fieldPath, sf.Type, err)
 		}
 	}
 	return nil
 }
 
+// setNestedDefault 处理嵌套结构体，nil 指针只在结构体包含默认值时才分配
+func setNestedDefault(cfg *viper.Viper, field reflect.Value, path, key string) error {
+	if field.Kind() == reflect.Struct {
+		return setStructDefault(cfg, field, path, key)
+	}
+	if field.IsNil() {
+		if !hasDefault(field.Type().Elem()) {
+			return nil
+		}
+		field.Set(reflect.New(field.Type().Elem()))
+	}
+	return setNestedDefault(cfg, field.Elem(), path, key)
+}
+
+// isSlice 判断类型（或其指向的类型）是否为切片
+func isSlice(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Slice
+}
+
+// hasDefault 判断类型中是否有字段设置了 default 标签
+func hasDefault(t reflect.Type) bool {
+	found := false
+	walkFields(t, func(f field) bool {
+		if def := f.Field.Tag.Get("default"); def != "" && def != "-" {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// setField 将 default 标签解析为字段类型的值
 func setField(field reflect.Value, defaultVal string) error {
-	if !field.CanSet() {
+	t := field.Type()
+	if t.Kind() == reflect.Ptr {
+		elem := reflect.New(t.Elem())
+		if err := setField(elem.Elem(), defaultVal); err != nil {
+			return err
+		}
+		field.Set(elem)
 		return nil
 	}
-	if !initializeField(field, defaultVal) {
+	switch {
+	case t == durationType:
+		val, err := time.ParseDuration(defaultVal)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(val))
 		return nil
+	case t == timeType:
+		val, err := parseTime(defaultVal)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(val))
+		return nil
+	case t == urlType:
+		val, err := url.Parse(defaultVal)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(*val))
+		return nil
+	case reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(defaultVal))
 	}
-	if isInitialValue(field) {
-		switch field.Kind() {
-		case reflect.Bool:
-			if val, err := strconv.ParseBool(defaultVal); err == nil {
-				field.Set(reflect.ValueOf(val).Convert(field.Type()))
-			}
-		case reflect.Int:
-			if val, err := strconv.ParseInt(defaultVal, 0, strconv.IntSize); err == nil {
-				field.Set(reflect.ValueOf(int(val)).Convert(field.Type()))
-			}
-		case reflect.Int8:
-			if val, err := strconv.ParseInt(defaultVal, 0, 8); err == nil {
-				field.Set(reflect.ValueOf(int8(val)).Convert(field.Type()))
-			}
-		case reflect.Int16:
-			if val, err := strconv.ParseInt(defaultVal, 0, 16); err == nil {
-				field.Set(reflect.ValueOf(int16(val)).Convert(field.Type()))
-			}
-		case reflect.Int32:
-			if val, err := strconv.ParseInt(defaultVal, 0, 32); err == nil {
-				field.Set(reflect.ValueOf(int32(val)).Convert(field.Type()))
-			}
-		case reflect.Int64:
-			if val, err := time.ParseDuration(defaultVal); err == nil {
-				field.Set(reflect.ValueOf(val).Convert(field.Type()))
-			} else if val, err := strconv.ParseInt(defaultVal, 0, 64); err == nil {
-				field.Set(reflect.ValueOf(val).Convert(field.Type()))
-			}
-		case reflect.Uint:
-			if val, err := strconv.ParseUint(defaultVal, 0, strconv.IntSize); err == nil {
-				field.Set(reflect.ValueOf(uint(val)).Convert(field.Type()))
-			}
-		case reflect.Uint8:
-			if val, err := strconv.ParseUint(defaultVal, 0, 8); err == nil {
-				field.Set(reflect.ValueOf(uint8(val)).Convert(field.Type()))
-			}
-		case reflect.Uint16:
-			if val, err := strconv.ParseUint(defaultVal, 0, 16); err == nil {
-				field.Set(reflect.ValueOf(uint16(val)).Convert(field.Type()))
-			}
-		case reflect.Uint32:
-			if val, err := strconv.ParseUint(defaultVal, 0, 32); err == nil {
-				field.Set(reflect.ValueOf(uint32(val)).Convert(field.Type()))
-			}
-		case reflect.Uint64:
-			if val, err := strconv.ParseUint(defaultVal, 0, 64); err == nil {
-				field.Set(reflect.ValueOf(val).Convert(field.Type()))
-			}
-		case reflect.Uintptr:
-			if val, err := strconv.ParseUint(defaultVal, 0, strconv.IntSize); err == nil {
-				field.Set(reflect.ValueOf(uintptr(val)).Convert(field.Type()))
+
+	switch t.Kind() {
+	case reflect.Bool:
+		val, err := strconv.ParseBool(defaultVal)
+		if err != nil {
+			return err
+		}
+		field.SetBool(val)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		val, err := strconv.ParseInt(defaultVal, 0, t.Bits())
+		if err != nil {
+			size, sizeErr := parseByteSize(defaultVal)
+			if sizeErr != nil || size > math.MaxInt64 || field.OverflowInt(int64(size)) {
+				return err
 			}
-		case reflect.Float32:
-			if val, err := strconv.ParseFloat(defaultVal, 32); err == nil {
-				field.Set(reflect.ValueOf(float32(val)).Convert(field.Type()))
+			val = int64(size)
+		}
+		field.SetInt(val)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		val, err := strconv.ParseUint(defaultVal, 0, t.Bits())
+		if err != nil {
+			size, sizeErr := parseByteSize(defaultVal)
+			if sizeErr != nil || field.OverflowUint(size) {
+				return err
 			}
-		case reflect.Float64:
-			if val, err := strconv.ParseFloat(defaultVal, 64); err == nil {
-				field.Set(reflect.ValueOf(val).Convert(field.Type()))
+			val = size
+		}
+		field.SetUint(val)
+	case reflect.Float32, reflect.Float64:
+		val, err := strconv.ParseFloat(defaultVal, t.Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(val)
+	case reflect.String:
+		field.SetString(defaultVal)
+	case reflect.Slice:
+		items := strings.Split(defaultVal, ",")
+		slice := reflect.MakeSlice(t, len(items), len(items))
+		for i, item := range items {
+			if err := setField(slice.Index(i), strings.TrimSpace(item)); err != nil {
+				return err
 			}
-		case reflect.String:
-			field.Set(reflect.ValueOf(defaultVal).Convert(field.Type()))
-		default:
-			println(defaultVal, field.Type().String())
 		}
+		field.Set(slice)
+	default:
+		return errors.New("unsupported type")
 	}
 	return nil
 }
 
-func isInitialValue(field reflect.Value) bool {
-	return reflect.DeepEqual(reflect.Zero(field.Type()).Interface(), field.Interface())
+// parseTime 支持 RFC3339 以及常用的日期时间格式
+func parseTime(s string) (time.Time, error) {
+	var err error
+	for _, layout := range []string{time.RFC3339Nano, time.DateTime, time.DateOnly} {
+		var t time.Time
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
 }
 
-func initializeField(field reflect.Value, tag string) bool {
-	switch field.Kind() {
-	case reflect.Struct:
-		return true
-	case reflect.Ptr:
-		if !field.IsNil() && field.Elem().Kind() == reflect.Struct {
-			return true
+// byteUnits 字节单位，KB/MB 等为 1000 进制，KiB/MiB 等为 1024 进制
+var byteUnits = []struct {
+	suffix string
+	size   uint64
+}{
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30}, {"TIB", 1 << 40}, {"PIB", 1 << 50},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12}, {"PB", 1e15},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40}, {"P", 1 << 50},
+	{"B", 1},
+}
+
+// parseByteSize 解析 100MB、1.5GiB、512K 这样的字节大小
+func parseByteSize(s string) (uint64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	for _, unit := range byteUnits {
+		if !strings.HasSuffix(upper, unit.suffix) {
+			continue
 		}
-	default:
-		return false
+		num := strings.TrimSpace(strings.TrimSuffix(upper, unit.suffix))
+		n, err := strconv.ParseFloat(num, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid byte size %q", s)
+		}
+		size := n * float64(unit.size)
+		if size > math.MaxUint64 {
+			return 0, fmt.Errorf("byte size %q overflows", s)
+		}
+		return uint64(size), nil
 	}
-	return tag != ""
+	return 0, fmt.Errorf("invalid byte size %q", s)
+}
+
+func isInitialValue(field reflect.Value) bool {
+	return field.IsZero()
 }
@@ -0,0 +1,133 @@
+package conf
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type defaultConfig struct {
+	Name     string        `default:"app"`
+	Enabled  bool          `default:"true"`
+	Workers  int8          `default:"0x10"`
+	Buffer   int           `default:"4KiB"`
+	Limit    uint32        `default:"1MB"`
+	Ratio    float64       `default:"0.5"`
+	Timeout  time.Duration `default:"1m30s"`
+	Start    time.Time     `default:"2024-05-16"`
+	Endpoint url.URL       `default:"https://example.com/api"`
+	IP       net.IP        `default:"127.0.0.1"`
+	Size     ByteSize      `default:"256MiB"`
+	Interval Duration      `default:"5s"`
+	Usage    Percent       `default:"75%"`
+	Hosts    []string      `default:"a, b"`
+	Ports    []int         `default:"80,443"`
+	Retries  *int          `default:"3"`
+	Skip     string        `default:"-"`
+	DB       *struct {
+		Host string `default:"localhost"`
+	}
+	Cache *struct {
+		Host string
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	var cfg defaultConfig
+	if _, err := LoadMap(nil, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	start, _ := time.Parse(time.DateOnly, "2024-05-16")
+	checks := []struct {
+		name string
+		ok   bool
+	}{
+		{"name", cfg.Name == "app"},
+		{"enabled", cfg.Enabled},
+		{"workers", cfg.Workers == 16},
+		{"buffer", cfg.Buffer == 4096},
+		{"limit", cfg.Limit == 1e6},
+		{"ratio", cfg.Ratio == 0.5},
+		{"timeout", cfg.Timeout == 90*time.Second},
+		{"start", cfg.Start.Equal(start)},
+		{"endpoint", cfg.Endpoint.String() == "https://example.com/api"},
+		{"ip", cfg.IP.String() == "127.0.0.1"},
+		{"size", cfg.Size == 256*MiB},
+		{"interval", cfg.Interval.Std() == 5*time.Second},
+		{"usage", cfg.Usage == 0.75},
+		{"hosts", strings.Join(cfg.Hosts, ",") == "a,b"},
+		{"ports", len(cfg.Ports) == 2 && cfg.Ports[0] == 80 && cfg.Ports[1] == 443},
+		{"retries", cfg.Retries != nil && *cfg.Retries == 3},
+		{"skip", cfg.Skip == ""},
+		{"db", cfg.DB != nil && cfg.DB.Host == "localhost"},
+		{"cache", cfg.Cache == nil},
+	}
+	for _, c := range checks {
+		if !c.ok {
+			t.Errorf("%s default not applied: %+v", c.name, cfg)
+		}
+	}
+}
+
+func TestSetDefaultConfigWins(t *testing.T) {
+	var cfg defaultConfig
+	_, err := LoadMap(map[string]any{"name": "file", "hosts": []any{"c"}, "db": map[string]any{"host": "db"}}, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "file" || cfg.DB.Host != "db" {
+		t.Errorf("got name %q db %q", cfg.Name, cfg.DB.Host)
+	}
+	// 配置中的切片比默认值短时不能残留默认值
+	if strings.Join(cfg.Hosts, ",") != "c" {
+		t.Errorf("hosts = %v, want [c]", cfg.Hosts)
+	}
+}
+
+func TestSetDefaultErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		v    any
+		msg  string
+	}{
+		{"int", &struct {
+			Port int `default:"eighty"`
+		}{}, `invalid default "eighty" for .Port (int)`},
+		{"overflow", &struct {
+			Small int8 `default:"1KiB"`
+		}{}, `for .Small (int8)`},
+		{"duration", &struct {
+			Timeout time.Duration `default:"5"`
+		}{}, `for .Timeout (time.Duration)`},
+		{"time", &struct {
+			Start time.Time `default:"yesterday"`
+		}{}, `for .Start (time.Time)`},
+		{"nested", &struct {
+			DB struct {
+				Port uint `default:"-1"`
+			}
+		}{}, `for .DB.Port (uint)`},
+		{"slice item", &struct {
+			Ports []int `default:"80,x"`
+		}{}, `for .Ports ([]int)`},
+		{"text unmarshaler", &struct {
+			Usage Percent `default:"most"`
+		}{}, `for .Usage (conf.Percent)`},
+		{"unsupported", &struct {
+			Ch chan int `default:"1"`
+		}{}, "unsupported type"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := LoadMap(nil, c.v)
+			if err == nil || !strings.Contains(err.Error(), c.msg) {
+				t.Errorf("error = %v, want one containing %q", err, c.msg)
+			}
+		})
+	}
+	if _, err := LoadMap(nil, struct{}{}); err == nil {
+		t.Error("non-pointer should fail")
+	}
+}
@@ -0,0 +1,53 @@
+package conf
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Diff 比较两个已加载的配置结构体最终生效的值，结果按 key 排序。
+// 脱敏规则与 Dump 相同，被脱敏的值发生变化时仍会报告，但只输出 Mask
+func Diff(a, b any, opts ...DumpOption) ([]Change, error) {
+	o := &dumpOptions{maskKeys: DefaultMaskKeys}
+	for _, opt := range opts {
+		opt(o)
+	}
+	va, err := diffValue(a)
+	if err != nil {
+		return nil, err
+	}
+	vb, err := diffValue(b)
+	if err != nil {
+		return nil, err
+	}
+	if va.Type() != vb.Type() {
+		return nil, fmt.Errorf("conf: diff %s with %s", va.Type(), vb.Type())
+	}
+	reveal := &dumpOptions{reveal: true}
+	changes := diffMaps(flatten(va, reveal), flatten(vb, reveal))
+	maskedA, maskedB := flatten(va, o), flatten(vb, o)
+	for i := range changes {
+		changes[i].Old = maskedA[changes[i].Key]
+		changes[i].New = maskedB[changes[i].Key]
+	}
+	return changes, nil
+}
+
+func diffValue(v any) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return rv, fmt.Errorf("conf: diff nil %T", v)
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return rv, fmt.Errorf("conf: diff requires a struct, got %T", v)
+	}
+	return rv, nil
+}
+
+func flatten(v reflect.Value, o *dumpOptions) map[string]any {
+	m, _ := dumpValue(v, "", false, o).(map[string]any)
+	return flattenMap(m, "")
+}
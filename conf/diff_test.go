@@ -0,0 +1,96 @@
+package conf
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type diffConfig struct {
+	Name     string
+	Timeout  time.Duration
+	Hosts    []string
+	Password string
+	Token    string `secret:"true"`
+	DSN      string
+	Labels   map[string]string
+	DB       *struct {
+		Port int
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := &diffConfig{
+		Name: "app", Timeout: time.Second, Hosts: []string{"a"}, Password: "old", Token: "same",
+		DSN: "root:old@tcp(db:3306)/app", Labels: map[string]string{"team": "infra", "tier": "1"},
+	}
+	b := &diffConfig{
+		Name: "app", Timeout: 2 * time.Second, Hosts: []string{"a", "b"}, Password: "new", Token: "same",
+		DSN: "root:new@tcp(db:3306)/app", Labels: map[string]string{"team": "infra", "zone": "x"},
+	}
+	b.DB = &struct{ Port int }{Port: 3306}
+
+	changes, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{
+		{Key: "db", Op: "removed"},
+		{Key: "db.port", Op: "added", New: 3306},
+		{Key: "dsn", Op: "changed", Old: "root:" + Mask + "@tcp(db:3306)/app", New: "root:" + Mask + "@tcp(db:3306)/app"},
+		{Key: "hosts", Op: "changed"},
+		{Key: "labels.tier", Op: "removed", Old: "1"},
+		{Key: "labels.zone", Op: "added", New: "x"},
+		{Key: "password", Op: "changed", Old: Mask, New: Mask},
+		{Key: "timeout", Op: "changed", Old: "1s", New: "2s"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("changes = %+v", changes)
+	}
+	for i, c := range want {
+		got := changes[i]
+		if got.Key != c.Key || got.Op != c.Op {
+			t.Errorf("change %d = %+v, want %+v", i, got, c)
+			continue
+		}
+		if c.Key != "hosts" && (got.Old != c.Old || got.New != c.New) {
+			t.Errorf("change %s = %v -> %v, want %v -> %v", c.Key, got.Old, got.New, c.Old, c.New)
+		}
+	}
+
+	// 自定义脱敏规则
+	changes, err = Diff(a, b, DumpMaskKeys(regexp.MustCompile(`^timeout$`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range changes {
+		if c.Key == "timeout" && c.New != Mask {
+			t.Errorf("timeout not masked: %+v", c)
+		}
+		if c.Key == "password" && c.New != "new" {
+			t.Errorf("password masked by default rules: %+v", c)
+		}
+	}
+
+	if changes, err = Diff(a, *a); err != nil || len(changes) != 0 {
+		t.Errorf("equal configs: %+v, %v", changes, err)
+	}
+}
+
+func TestDiffErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b any
+		msg  string
+	}{
+		{"nil", (*diffConfig)(nil), &diffConfig{}, "diff nil"},
+		{"not a struct", 1, &diffConfig{}, "requires a struct"},
+		{"different types", &diffConfig{}, &struct{ Name string }{}, "diff conf.diffConfig with struct"},
+	}
+	for _, c := range cases {
+		if _, err := Diff(c.a, c.b); err == nil || !strings.Contains(err.Error(), c.msg) {
+			t.Errorf("%s: error = %v, want %q", c.name, err, c.msg)
+		}
+	}
+}
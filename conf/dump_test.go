@@ -0,0 +1,145 @@
+package conf
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type dumpConfig struct {
+	Name    string        `default:"app"`
+	Port    int           `default:"80"`
+	Timeout time.Duration `default:"1s"`
+	Size    ByteSize
+	APIKey  string `secret:"true"`
+	Token   string
+	Labels  map[string]string
+	DB      struct {
+		DSN      string
+		URL      string
+		Password string
+	}
+}
+
+func loadDumpConfig(t *testing.T) (*dumpConfig, map[string]string) {
+	t.Helper()
+	t.Setenv("PORT", "8080")
+	var cfg dumpConfig
+	var sources map[string]string
+	_, err := LoadMap(map[string]any{
+		"size":   "1MiB",
+		"apikey": "key",
+		"token":  "tok",
+		"labels": map[string]any{"team": "infra"},
+		"db": map[string]any{
+			"dsn":      "root:pass@tcp(db:3306)/app",
+			"url":      "postgres://user:pass@db/app?sslmode=disable password=kv",
+			"password": "pass",
+		},
+	}, &cfg, WithProvenance(&sources))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &cfg, sources
+}
+
+func TestDumpYAML(t *testing.T) {
+	cfg, sources := loadDumpConfig(t)
+	out, err := Dump(cfg, DumpSources(sources))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+	for _, line := range []string{
+		"name: app # default",
+		"port: 8080 # env",
+		"timeout: 1s # default",
+		"size: 1MiB # file",
+		"apikey: '" + Mask + "' # file",
+		"token: '" + Mask + "' # file",
+		"labels: # file",
+		"dsn: root:" + Mask + "@tcp(db:3306)/app # file",
+		"url: postgres://user:" + Mask + "@db/app?sslmode=disable password=" + Mask + " # file",
+		"password: '" + Mask + "' # file",
+	} {
+		if !strings.Contains(got, line) {
+			t.Errorf("dump does not contain %q:\n%s", line, got)
+		}
+	}
+	if strings.Contains(got, "pass@") || strings.Contains(got, "tok\n") || strings.Contains(got, "key #") {
+		t.Errorf("dump leaks a secret:\n%s", got)
+	}
+
+	// 自定义脱敏规则替换默认规则，secret 标签和 DSN 密码仍然脱敏
+	out, err = Dump(cfg, DumpMaskKeys(regexp.MustCompile(`^name$`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = string(out)
+	for _, line := range []string{"name: '" + Mask + "'", "token: tok", "apikey: '" + Mask + "'", "dsn: root:" + Mask + "@"} {
+		if !strings.Contains(got, line) {
+			t.Errorf("dump with custom mask keys does not contain %q:\n%s", line, got)
+		}
+	}
+}
+
+func TestDumpJSON(t *testing.T) {
+	cfg, sources := loadDumpConfig(t)
+	out, err := Dump(cfg, DumpJSON(), DumpSources(sources))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Config  map[string]any    `json:"config"`
+		Sources map[string]string `json:"sources"`
+	}
+	if err = json.Unmarshal(out, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Config["port"] != float64(8080) || got.Config["apikey"] != Mask || got.Config["size"] != "1MiB" {
+		t.Errorf("config = %v", got.Config)
+	}
+	if db := got.Config["db"].(map[string]any); db["password"] != Mask {
+		t.Errorf("db = %v", db)
+	}
+	if got.Sources["port"] != SourceEnv || got.Sources["name"] != SourceDefault || got.Sources["db.dsn"] != SourceFile {
+		t.Errorf("sources = %v", got.Sources)
+	}
+}
+
+func TestDumpErrors(t *testing.T) {
+	for _, v := range []any{nil, (*dumpConfig)(nil), 1, "config"} {
+		if _, err := Dump(v); err == nil {
+			t.Errorf("Dump(%#v) should fail", v)
+		}
+	}
+}
+
+func TestDumpHandler(t *testing.T) {
+	cfg, sources := loadDumpConfig(t)
+	h := DumpHandler(func() any { return cfg }, DumpSources(sources))
+	for _, c := range []struct {
+		url, contentType, contains string
+	}{
+		{"/config", "application/yaml", "port: 8080 # env"},
+		{"/config?format=json", "application/json", `"port": 8080`},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", c.url, nil))
+		if rec.Code != 200 || !strings.HasPrefix(rec.Header().Get("Content-Type"), c.contentType) {
+			t.Errorf("%s: %d %s", c.url, rec.Code, rec.Header().Get("Content-Type"))
+		}
+		if body := rec.Body.String(); !strings.Contains(body, c.contains) || strings.Contains(body, "pass@") {
+			t.Errorf("%s body:\n%s", c.url, body)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	DumpHandler(func() any { return nil }).ServeHTTP(rec, httptest.NewRequest("GET", "/config", nil))
+	if rec.Code != 500 {
+		t.Errorf("nil config status = %d, want 500", rec.Code)
+	}
+}
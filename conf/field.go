@@ -0,0 +1,88 @@
+package conf
+
+import (
+	"encoding"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	urlType             = reflect.TypeOf(url.URL{})
+)
+
+// field 配置结构体中的一个字段
+type field struct {
+	Path   []string // 字段路径（Go 字段名），用于错误信息
+	Key    string   // 对应 viper 的 key，小写并以 "." 连接
+	Field  reflect.StructField
+	Nested bool // 是否为会继续展开的嵌套结构体
+}
+
+// keyName 返回字段在配置文件中的名字，与 mapstructure 的规则一致
+func keyName(f reflect.StructField) (name string, squash bool) {
+	tag := f.Tag.Get("mapstructure")
+	if tag != "" {
+		parts := strings.Split(tag, ",")
+		name = parts[0]
+		for _, opt := range parts[1:] {
+			if opt == "squash" {
+				squash = true
+			}
+		}
+	}
+	if name == "" {
+		name = f.Name
+	}
+	return strings.ToLower(name), squash
+}
+
+// walkFields 遍历结构体的所有可导出字段，嵌套结构体会先回调自身再展开
+func walkFields(t reflect.Type, fn func(f field) bool) {
+	walk(t, nil, nil, map[reflect.Type]bool{}, fn)
+}
+
+func walk(t reflect.Type, path, keys []string, visiting map[reflect.Type]bool, fn func(f field) bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || visiting[t] {
+		return
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() || sf.Tag.Get("mapstructure") == "-" {
+			continue
+		}
+		name, squash := keyName(sf)
+		if squash {
+			walk(sf.Type, path, keys, visiting, fn)
+			continue
+		}
+		f := field{
+			Path:   append(append([]string{}, path...), sf.Name),
+			Key:    strings.Join(append(append([]string{}, keys...), name), "."),
+			Field:  sf,
+			Nested: isNested(sf.Type),
+		}
+		if !fn(f) || !f.Nested {
+			continue
+		}
+		walk(sf.Type, f.Path, append(append([]string{}, keys...), name), visiting, fn)
+	}
+}
+
+// isNested 判断字段是否为需要展开的结构体，time.Time、url.URL 等按单个值处理
+func isNested(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == urlType {
+		return false
+	}
+	return !reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
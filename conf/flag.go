@@ -0,0 +1,185 @@
+package conf
+
+import (
+	"encoding"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// ErrHelp 命令行参数包含 -h/--help 时 Load 打印帮助并返回该错误
+var ErrHelp = pflag.ErrHelp
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// flagValue 以字符串保存参数值，最终的类型转换交给 viper 的 Unmarshal
+type flagValue struct {
+	value string
+	typ   string
+	parse func(string) error
+}
+
+func (f *flagValue) String() string { return f.value }
+
+func (f *flagValue) Type() string { return f.typ }
+
+func (f *flagValue) Set(s string) error {
+	if f.parse != nil {
+		if err := f.parse(s); err != nil {
+			return err
+		}
+	}
+	f.value = s
+	return nil
+}
+
+// FlagSet 根据配置结构体生成命令行参数，参数名为配置的 key（如 --db.maxopenconn），
+// 说明取自 usage 标签，默认值取自 default 标签，flag:"-" 的字段不生成参数
+func FlagSet(v any) *pflag.FlagSet {
+	return newFlagSet(filepath.Base(os.Args[0]), v, os.Stderr)
+}
+
+func newFlagSet(name string, v any, output io.Writer) *pflag.FlagSet {
+	fs := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	fs.SortFlags = false
+	fs.SetOutput(output)
+	walkFields(reflect.TypeOf(v), func(f field) bool {
+		if f.Field.Tag.Get("flag") == "-" {
+			return false
+		}
+		if f.Nested {
+			return true
+		}
+		typ, parse, ok := flagType(f.Field.Type)
+		if !ok {
+			return true
+		}
+		def := f.Field.Tag.Get("default")
+		if def == "-" {
+			def = ""
+		}
+		flag := fs.VarPF(&flagValue{value: def, typ: typ, parse: parse}, f.Key, "", f.Field.Tag.Get("usage"))
+		if typ == "bool" {
+			flag.NoOptDefVal = "true"
+		}
+		return true
+	})
+	fs.Usage = func() {
+		printUsage(name, fs, output)
+	}
+	return fs
+}
+
+// parseFlags 解析命令行参数，配置结构体中没有 profile 时额外提供 --profile 用于选择 profile
+func parseFlags(v any, o *options) (*pflag.FlagSet, error) {
+	fs := newFlagSet(filepath.Base(os.Args[0]), v, o.output)
+	if fs.Lookup(profileFlag) == nil {
+		fs.String(profileFlag, "", "configuration profile, overrides $"+EnvProfile)
+		_ = fs.SetAnnotation(profileFlag, profileFlag, nil)
+	}
+	if err := fs.Parse(o.args); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+// bindFlags 将命令行中出现过的参数绑定到对应的 key，返回已绑定的 key
+func bindFlags(cfg *viper.Viper, fs *pflag.FlagSet) (map[string]bool, error) {
+	bound := map[string]bool{}
+	var err error
+	fs.Visit(func(flag *pflag.Flag) {
+		if _, builtin := flag.Annotations[profileFlag]; builtin {
+			return
+		}
+		if err == nil {
+			err = cfg.BindPFlag(flag.Name, flag)
+			bound[flag.Name] = true
+		}
+	})
+	return bound, err
+}
+
+// printUsage 按配置的一级 key 分组输出帮助
+func printUsage(name string, fs *pflag.FlagSet, w io.Writer) {
+	var sections []string
+	groups := map[string]*pflag.FlagSet{}
+	fs.VisitAll(func(flag *pflag.Flag) {
+		section := "general"
+		if i := strings.Index(flag.Name, "."); i > 0 {
+			section = flag.Name[:i]
+		}
+		group, ok := groups[section]
+		if !ok {
+			group = pflag.NewFlagSet(section, pflag.ContinueOnError)
+			group.SortFlags = false
+			groups[section] = group
+			sections = append(sections, section)
+		}
+		group.AddFlag(flag)
+	})
+	fmt.Fprintf(w, "Usage of %s:\n", name)
+	for _, section := range sections {
+		fmt.Fprintf(w, "\n%s:\n%s", section, groups[section].FlagUsages())
+	}
+}
+
+// flagType 返回字段类型对应的参数类型名和校验函数，不支持的类型返回 false
+func flagType(t reflect.Type) (string, func(string) error, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == durationType {
+		return "duration", func(s string) error {
+			_, err := time.ParseDuration(s)
+			return err
+		}, true
+	}
+	if t == urlType || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return strings.ToLower(t.Name()), func(s string) error {
+			if u, ok := reflect.New(t).Interface().(encoding.TextUnmarshaler); ok {
+				return u.UnmarshalText([]byte(s))
+			}
+			return nil
+		}, true
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "bool", func(s string) error {
+			_, err := strconv.ParseBool(s)
+			return err
+		}, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return t.Kind().String(), func(s string) error {
+			_, err := strconv.ParseInt(s, 0, t.Bits())
+			return err
+		}, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return t.Kind().String(), func(s string) error {
+			_, err := strconv.ParseUint(s, 0, t.Bits())
+			return err
+		}, true
+	case reflect.Float32, reflect.Float64:
+		return t.Kind().String(), func(s string) error {
+			_, err := strconv.ParseFloat(s, t.Bits())
+			return err
+		}, true
+	case reflect.String:
+		return "string", nil, true
+	case reflect.Slice:
+		switch t.Elem().Kind() {
+		case reflect.String:
+			return "stringSlice", nil, true
+		case reflect.Int:
+			return "intSlice", nil, true
+		}
+	}
+	return "", nil, false
+}
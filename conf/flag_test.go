@@ -0,0 +1,126 @@
+package conf
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type flagConfig struct {
+	Name    string        `default:"app" usage:"application name"`
+	Port    int           `default:"80"`
+	Debug   bool          `default:"false"`
+	Timeout time.Duration `default:"1s"`
+	Tags    []string
+	Secret  string `flag:"-"`
+	DB      struct {
+		Host string `default:"localhost"`
+		Port int    `default:"3306"`
+	}
+}
+
+func TestFlagPrecedence(t *testing.T) {
+	file := map[string]any{"name": "file", "port": 81, "db": map[string]any{"host": "file-host"}}
+	want := func(fn func(c *flagConfig)) flagConfig {
+		c := &flagConfig{Name: "file", Port: 81, Timeout: time.Second}
+		c.DB.Host, c.DB.Port = "file-host", 3306
+		fn(c)
+		return *c
+	}
+	cases := []struct {
+		name   string
+		args   []string
+		env    map[string]string
+		want   flagConfig
+		source string // port 的来源
+	}{
+		{
+			name:   "file over default",
+			want:   want(func(c *flagConfig) {}),
+			source: SourceFile,
+		},
+		{
+			name:   "env over file",
+			env:    map[string]string{"PORT": "82"},
+			want:   want(func(c *flagConfig) { c.Port = 82 }),
+			source: SourceEnv,
+		},
+		{
+			name:   "env over default",
+			env:    map[string]string{"DEBUG": "true", "DB.PORT": "3307"},
+			want:   want(func(c *flagConfig) { c.Debug, c.DB.Port = true, 3307 }),
+			source: SourceFile,
+		},
+		{
+			name: "flag over env",
+			args: []string{"--port", "83", "--db.host=flag-host", "--debug", "--tags", "a,b"},
+			env:  map[string]string{"PORT": "82"},
+			want: want(func(c *flagConfig) {
+				c.Port, c.Debug, c.Tags, c.DB.Host = 83, true, []string{"a", "b"}, "flag-host"
+			}),
+			source: SourceFlag,
+		},
+		{
+			name:   "unset flags keep file values",
+			args:   []string{"--timeout", "5s"},
+			want:   want(func(c *flagConfig) { c.Timeout = 5 * time.Second }),
+			source: SourceFile,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for k, v := range c.env {
+				t.Setenv(k, v)
+			}
+			var cfg flagConfig
+			var sources map[string]string
+			if _, err := LoadMap(file, &cfg, WithFlags(c.args), WithProvenance(&sources)); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cfg, c.want) {
+				t.Errorf("got %+v, want %+v", cfg, c.want)
+			}
+			if sources["port"] != c.source {
+				t.Errorf("port source = %s, want %s", sources["port"], c.source)
+			}
+		})
+	}
+}
+
+func TestFlagSet(t *testing.T) {
+	fs := newFlagSet("app", &flagConfig{}, &bytes.Buffer{})
+	for _, name := range []string{"name", "port", "debug", "timeout", "tags", "db.host", "db.port"} {
+		if fs.Lookup(name) == nil {
+			t.Errorf("flag --%s missing", name)
+		}
+	}
+	if fs.Lookup("secret") != nil {
+		t.Error(`flag:"-" field has a flag`)
+	}
+	if f := fs.Lookup("db.port"); f.DefValue != "3306" {
+		t.Errorf("db.port default = %q", f.DefValue)
+	}
+	if f := fs.Lookup("name"); f.Usage != "application name" {
+		t.Errorf("name usage = %q", f.Usage)
+	}
+}
+
+func TestFlagErrors(t *testing.T) {
+	var out bytes.Buffer
+	var cfg flagConfig
+	_, err := LoadMap(nil, &cfg, WithFlags([]string{"--help"}), WithOutput(&out))
+	if !errors.Is(err, ErrHelp) {
+		t.Errorf("--help error = %v", err)
+	}
+	if !strings.Contains(out.String(), "db:") || !strings.Contains(out.String(), "--db.host") {
+		t.Errorf("usage not grouped by section:\n%s", out.String())
+	}
+	for _, args := range [][]string{{"--port", "eighty"}, {"--timeout", "5"}, {"--unknown", "1"}} {
+		if _, err := LoadMap(nil, &cfg, WithFlags(args), WithOutput(&out)); err == nil {
+			t.Errorf("%v should fail", args)
+		}
+	}
+}
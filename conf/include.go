@@ -0,0 +1,221 @@
+package conf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// includeKey 配置文件中用于引入其他文件的 key，支持字符串或列表、通配符以及相对路径
+const includeKey = "include"
+
+// files 读取配置文件所需的文件系统操作，本地文件与 fs.FS 各有一套实现
+type files struct {
+	readFile func(name string) ([]byte, error)
+	glob     func(pattern string) ([]string, error)
+	join     func(elem ...string) string
+	dir      func(name string) string
+	abs      func(name string) (string, error)
+	isAbs    func(name string) bool
+	readDir  func(name string) ([]string, error)
+}
+
+var osFiles = &files{
+	readFile: os.ReadFile,
+	glob:     filepath.Glob,
+	join:     filepath.Join,
+	dir:      filepath.Dir,
+	abs:      filepath.Abs,
+	isAbs:    filepath.IsAbs,
+	readDir: func(name string) ([]string, error) {
+		entries, err := os.ReadDir(name)
+		return entryNames(entries), err
+	},
+}
+
+func fsFiles(fsys fs.FS) *files {
+	return &files{
+		readFile: func(name string) ([]byte, error) { return fs.ReadFile(fsys, name) },
+		glob:     func(pattern string) ([]string, error) { return fs.Glob(fsys, pattern) },
+		join:     path.Join,
+		dir:      path.Dir,
+		abs:      func(name string) (string, error) { return path.Clean(name), nil },
+		isAbs:    func(string) bool { return false },
+		readDir: func(name string) ([]string, error) {
+			entries, err := fs.ReadDir(fsys, name)
+			return entryNames(entries), err
+		},
+	}
+}
+
+func entryNames(entries []fs.DirEntry) []string {
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+// readTree 读取配置文件并递归处理 include，被引入的文件先合并，当前文件的内容覆盖它们
+func (f *files) readTree(name string, stack []string) (map[string]any, error) {
+	abs, err := f.abs(name)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range stack {
+		if p == abs {
+			return nil, fmt.Errorf("conf: include cycle: %s", strings.Join(append(stack[i:], abs), " -> "))
+		}
+	}
+	stack = append(stack, abs)
+
+	data, err := f.readFile(name)
+	if err != nil {
+		return nil, err
+	}
+	own, err := parseConfig(data, formatOf(name))
+	if err != nil {
+		return nil, fmt.Errorf("conf: %s: %w", name, err)
+	}
+	patterns, err := includePatterns(own[includeKey])
+	if err != nil {
+		return nil, fmt.Errorf("conf: %s: %w", name, err)
+	}
+	delete(own, includeKey)
+
+	merged := map[string]any{}
+	for _, pattern := range patterns {
+		if !f.isAbs(pattern) {
+			pattern = f.join(f.dir(name), pattern)
+		}
+		matches, err := f.glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("conf: %s: include %q: %w", name, pattern, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			return nil, fmt.Errorf("conf: %s: include %q: file not found", name, pattern)
+		}
+		sort.Strings(matches)
+		for _, match := range matches {
+			sub, err := f.readTree(match, stack)
+			if err != nil {
+				return nil, fmt.Errorf("%w (included from %s)", err, name)
+			}
+			mergeMap(merged, sub)
+		}
+	}
+	mergeMap(merged, own)
+	return merged, nil
+}
+
+// readWithOverlay 读取配置文件，存在 profile 覆盖文件（如 config.prod.yaml）时合并到上面
+func (f *files) readWithOverlay(name, profile string) (map[string]any, error) {
+	m, err := f.readTree(name, nil)
+	if err != nil || profile == "" {
+		return m, err
+	}
+	ext := path.Ext(name)
+	overlay := strings.TrimSuffix(name, ext) + "." + profile + ext
+	sub, err := f.readTree(overlay, nil)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	mergeMap(m, sub)
+	return m, nil
+}
+
+// readFragments 按文件名字典序读取目录中所有支持的配置文件，后面的文件覆盖前面的
+func (f *files) readFragments(dir string) (map[string]any, error) {
+	names, err := f.readDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	merged := map[string]any{}
+	for _, name := range names {
+		if !supportedFormat(formatOf(name)) {
+			continue
+		}
+		sub, err := f.readTree(f.join(dir, name), nil)
+		if err != nil {
+			return nil, err
+		}
+		mergeMap(merged, sub)
+	}
+	return merged, nil
+}
+
+func supportedFormat(format string) bool {
+	for _, ext := range viper.SupportedExts {
+		if ext == format {
+			return true
+		}
+	}
+	return false
+}
+
+func includePatterns(val any) ([]string, error) {
+	switch v := val.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []any:
+		patterns := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must be a string or a list of strings", includeKey)
+			}
+			patterns = append(patterns, s)
+		}
+		return patterns, nil
+	}
+	return nil, fmt.Errorf("%s must be a string or a list of strings", includeKey)
+}
+
+// parseConfig 解析单个配置文件，key 统一转为小写；JSON 的语法错误补充行号
+func parseConfig(data []byte, format string) (map[string]any, error) {
+	v := viper.New()
+	v.SetConfigType(format)
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line := bytes.Count(data[:syntaxErr.Offset], []byte("\n")) + 1
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		return nil, err
+	}
+	return v.AllSettings(), nil
+}
+
+// mergeMap 将 src 深度合并到 dst，同名的 map 递归合并，其余类型直接覆盖
+func mergeMap(dst, src map[string]any) {
+	for k, sv := range src {
+		if sm, ok := sv.(map[string]any); ok {
+			if dm, ok := dst[k].(map[string]any); ok {
+				mergeMap(dm, sm)
+				continue
+			}
+			cp := map[string]any{}
+			mergeMap(cp, sm)
+			dst[k] = cp
+			continue
+		}
+		dst[k] = sv
+	}
+}
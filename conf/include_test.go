@@ -0,0 +1,100 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles 在临时目录中写入配置文件，返回目录
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+type includeConfig struct {
+	Name  string
+	Port  int
+	Level string
+	Hosts []string
+}
+
+func TestInclude(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml":         "include: [base.yaml, 'parts/*.yaml']\nname: main\n",
+		"base.yaml":           "name: base\nport: 80\nlevel: debug\n",
+		"parts/a.yaml":        "port: 81\nhosts: [a]\n",
+		"parts/b.yaml":        "include: ../shared/level.json\nport: 82\n",
+		"shared/level.json":   `{"level": "warn"}`,
+		"conf.d/10-port.yaml": "port: 90\n",
+		"conf.d/20-port.yaml": "port: 91\nhosts: [b]\n",
+		"conf.d/README.md":    "not a config",
+	})
+	var cfg includeConfig
+	if _, err := Load(filepath.Join(dir, "config.yaml"), &cfg, WithoutEnvPath()); err != nil {
+		t.Fatal(err)
+	}
+	// 当前文件覆盖被引入的文件，通配符按文件名排序合并
+	if cfg.Name != "main" || cfg.Port != 82 || cfg.Level != "warn" || strings.Join(cfg.Hosts, ",") != "a" {
+		t.Errorf("got %+v", cfg)
+	}
+
+	cfg = includeConfig{}
+	if _, err := Load(filepath.Join(dir, "config.yaml"), &cfg, WithoutEnvPath(), WithConfDir(filepath.Join(dir, "conf.d"))); err != nil {
+		t.Fatal(err)
+	}
+	// 片段目录覆盖主配置，后面的文件覆盖前面的
+	if cfg.Name != "main" || cfg.Port != 91 || strings.Join(cfg.Hosts, ",") != "b" {
+		t.Errorf("with conf.d got %+v", cfg)
+	}
+}
+
+func TestIncludeErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"cycle.yaml":    "include: a.yaml\n",
+		"a.yaml":        "include: b.yaml\n",
+		"b.yaml":        "include: a.yaml\n",
+		"self.yaml":     "include: self.yaml\n",
+		"missing.yaml":  "include: nothing.yaml\n",
+		"nomatch.yaml":  "include: 'none/*.yaml'\nname: ok\n",
+		"badtype.yaml":  "include: 1\n",
+		"badparse.yaml": "include: broken.json\n",
+		"broken.json":   "{\n\"name\": \n}",
+	})
+	cases := []struct {
+		file string
+		msg  string
+	}{
+		{"cycle.yaml", "include cycle: " + filepath.Join(dir, "a.yaml") + " -> " + filepath.Join(dir, "b.yaml") + " -> " + filepath.Join(dir, "a.yaml")},
+		{"self.yaml", "include cycle"},
+		{"missing.yaml", "file not found"},
+		{"badtype.yaml", "include must be a string or a list of strings"},
+		{"badparse.yaml", "broken.json: line 3"},
+	}
+	for _, c := range cases {
+		t.Run(c.file, func(t *testing.T) {
+			var cfg includeConfig
+			_, err := Load(filepath.Join(dir, c.file), &cfg, WithoutEnvPath())
+			if err == nil || !strings.Contains(err.Error(), c.msg) {
+				t.Errorf("error = %v, want one containing %q", err, c.msg)
+			}
+		})
+	}
+
+	// 没有匹配的通配符不是错误
+	var cfg includeConfig
+	if _, err := Load(filepath.Join(dir, "nomatch.yaml"), &cfg, WithoutEnvPath()); err != nil || cfg.Name != "ok" {
+		t.Errorf("glob without matches: %+v, %v", cfg, err)
+	}
+}
@@ -0,0 +1,25 @@
+package conf
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+// logger 输出废弃 key、重新加载失败等警告，默认使用标准库 log
+var logger atomic.Pointer[func(format string, args ...any)]
+
+// SetLogger 设置 conf 输出警告使用的日志函数，如 zap 的 SugaredLogger.Warnf
+func SetLogger(fn func(format string, args ...any)) {
+	if fn == nil {
+		fn = log.Printf
+	}
+	logger.Store(&fn)
+}
+
+func logf(format string, args ...any) {
+	if fn := logger.Load(); fn != nil {
+		(*fn)(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
@@ -0,0 +1,98 @@
+package conf
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Markdown 根据配置结构体生成 Markdown 格式的配置参考文档，按嵌套结构分节
+func Markdown(v any) ([]byte, error) {
+	s, err := NewSchema(v)
+	if err != nil {
+		return nil, err
+	}
+	var sections []string
+	rows := map[string][]string{}
+	walkFields(reflect.TypeOf(v), func(f field) bool {
+		if f.Nested {
+			return true
+		}
+		section := ""
+		if i := strings.LastIndex(f.Key, "."); i > 0 {
+			section = f.Key[:i]
+		}
+		if _, ok := rows[section]; !ok {
+			sections = append(sections, section)
+		}
+		rows[section] = append(rows[section], markdownRow(s, f))
+		return true
+	})
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n", s.Title)
+	for _, section := range sections {
+		title := section
+		if title == "" {
+			title = "(root)"
+		}
+		fmt.Fprintf(&buf, "\n## %s\n\n", title)
+		buf.WriteString("| Key | Type | Default | Constraints | Description |\n")
+		buf.WriteString("|-----|------|---------|-------------|-------------|\n")
+		for _, row := range rows[section] {
+			buf.WriteString(row)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func markdownRow(root *Schema, f field) string {
+	s := root.lookup(f.Key)
+	if s == nil {
+		s = &Schema{}
+	}
+	typ := s.Type
+	if s.Format != "" {
+		typ = s.Format
+	}
+	if s.Type == "array" && s.Items != nil {
+		typ = "[]" + s.Items.Type
+	}
+	var constraints []string
+	if r, err := parseRules(f.Field.Tag.Get("validate")); err == nil {
+		if r.required {
+			constraints = append(constraints, "required")
+		}
+		if r.min != "" {
+			constraints = append(constraints, "min="+r.min)
+		}
+		if r.max != "" {
+			constraints = append(constraints, "max="+r.max)
+		}
+		if len(r.oneof) > 0 {
+			constraints = append(constraints, "one of: "+strings.Join(r.oneof, ", "))
+		}
+	}
+	if s.Secret {
+		constraints = append(constraints, "secret")
+	}
+	def := ""
+	if s.Default != nil {
+		def = fmt.Sprintf("`%v`", s.Default)
+	}
+	return fmt.Sprintf("| `%s` | %s | %s | %s | %s |\n", f.Key, typ, def,
+		strings.Join(constraints, ", "), strings.ReplaceAll(s.Description, "|", "\\|"))
+}
+
+// lookup 按 key 路径查找子 schema
+func (s *Schema) lookup(key string) *Schema {
+	cur := s
+	for _, part := range strings.Split(key, ".") {
+		if cur == nil || cur.Properties == nil {
+			return nil
+		}
+		cur = cur.Properties[part]
+	}
+	return cur
+}
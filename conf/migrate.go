@@ -0,0 +1,180 @@
+package conf
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// versionKey 配置文件中表示配置格式版本的 key，未写时视为版本 0
+const versionKey = "version"
+
+// Migration 将原始配置从 From 版本升级到 From+1
+type Migration struct {
+	From        int
+	Description string
+	Migrate     func(m map[string]any) error
+}
+
+var (
+	migrationsMu sync.RWMutex
+	migrations   = map[int]Migration{}
+)
+
+// RegisterMigration 注册从 from 升级到 from+1 的迁移函数，fn 直接修改原始配置（key 均为小写）
+func RegisterMigration(from int, description string, fn func(m map[string]any) error) {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	if _, ok := migrations[from]; ok {
+		panic(fmt.Sprintf("conf: migration from version %d already registered", from))
+	}
+	migrations[from] = Migration{From: from, Description: description, Migrate: fn}
+}
+
+// LatestVersion 返回已注册迁移能升级到的最新版本，没有注册迁移时为 0
+func LatestVersion() int {
+	migrationsMu.RLock()
+	defer migrationsMu.RUnlock()
+	latest := 0
+	for from := range migrations {
+		if from+1 > latest {
+			latest = from + 1
+		}
+	}
+	return latest
+}
+
+// MigrationReport 迁移报告
+type MigrationReport struct {
+	From    int      `json:"from"`
+	To      int      `json:"to"`
+	Applied []string `json:"applied"`
+	Changes []Change `json:"changes"`
+}
+
+// Change 单个 key 的变化，Op 为 added、removed 或 changed
+type Change struct {
+	Key string `json:"key"`
+	Op  string `json:"op"`
+	Old any    `json:"old,omitempty"`
+	New any    `json:"new,omitempty"`
+}
+
+// Migrate 将原始配置逐版本升级到最新版本，并写入新的 version
+func Migrate(m map[string]any) (*MigrationReport, error) {
+	from := 0
+	if raw, ok := m[versionKey]; ok {
+		v, err := cast.ToIntE(raw)
+		if err != nil {
+			return nil, fmt.Errorf("conf: invalid %s %v", versionKey, raw)
+		}
+		from = v
+	}
+	latest := LatestVersion()
+	report := &MigrationReport{From: from, To: from}
+	if from > latest && latest > 0 {
+		return nil, fmt.Errorf("conf: config version %d is newer than the latest supported version %d", from, latest)
+	}
+	if from == latest {
+		return report, nil
+	}
+
+	before := flattenMap(m, "")
+	migrationsMu.RLock()
+	defer migrationsMu.RUnlock()
+	for version := from; version < latest; version++ {
+		migration, ok := migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("conf: no migration registered from version %d", version)
+		}
+		if err := migration.Migrate(m); err != nil {
+			return nil, fmt.Errorf("conf: migrate from version %d: %w", version, err)
+		}
+		report.Applied = append(report.Applied, fmt.Sprintf("%d -> %d: %s", version, version+1, migration.Description))
+	}
+	m[versionKey] = latest
+	report.To = latest
+	report.Changes = diffMaps(before, flattenMap(m, ""))
+	return report, nil
+}
+
+// migrateConfig 对已读入 viper 的原始配置执行迁移，有变化时返回新的 viper
+func migrateConfig(cfg *viper.Viper) (*viper.Viper, error) {
+	if LatestVersion() == 0 {
+		return cfg, nil
+	}
+	raw := cfg.AllSettings()
+	report, err := Migrate(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Applied) == 0 {
+		return cfg, nil
+	}
+	return rebuild(cfg, raw)
+}
+
+// MigrateFile 读取单个配置文件并升级到最新版本，write 为 true 时写回原文件（注释和 key 的顺序不会保留）
+func MigrateFile(path string, write bool) (*MigrationReport, error) {
+	data, err := osFiles.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := parseConfig(data, formatOf(path))
+	if err != nil {
+		return nil, fmt.Errorf("conf: %s: %w", path, err)
+	}
+	report, err := Migrate(m)
+	if err != nil || !write || len(report.Applied) == 0 {
+		return report, err
+	}
+	out := viper.New()
+	if err = out.MergeConfigMap(m); err != nil {
+		return nil, err
+	}
+	return report, out.WriteConfigAs(path)
+}
+
+// flattenMap 将嵌套 map 展开为 a.b.c 形式的 key
+func flattenMap(m map[string]any, prefix string) map[string]any {
+	out := map[string]any{}
+	for k, v := range m {
+		key := joinKey(prefix, strings.ToLower(k))
+		if sub, ok := toStringMap(v); ok && len(sub) > 0 {
+			for sk, sv := range flattenMap(sub, key) {
+				out[sk] = sv
+			}
+			continue
+		}
+		out[key] = v
+	}
+	return out
+}
+
+// diffMaps 比较两个展开后的 map，结果按 key 排序
+func diffMaps(before, after map[string]any) []Change {
+	var changes []Change
+	for k, old := range before {
+		nv, ok := after[k]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Key: k, Op: "removed", Old: old})
+		case !reflect.DeepEqual(old, nv):
+			changes = append(changes, Change{Key: k, Op: "changed", Old: old, New: nv})
+		}
+	}
+	for k, nv := range after {
+		if _, ok := before[k]; !ok {
+			changes = append(changes, Change{Key: k, Op: "added", New: nv})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
@@ -0,0 +1,162 @@
+package conf
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// resetMigrations 使用空的迁移注册表，测试结束后恢复
+func resetMigrations(t *testing.T) {
+	t.Helper()
+	migrationsMu.Lock()
+	saved := migrations
+	migrations = map[int]Migration{}
+	migrationsMu.Unlock()
+	t.Cleanup(func() {
+		migrationsMu.Lock()
+		migrations = saved
+		migrationsMu.Unlock()
+	})
+}
+
+// registerTestMigrations 版本 0 -> 1 将 db_host 移到 db.host，1 -> 2 将秒数形式的 timeout 改为带单位
+func registerTestMigrations(t *testing.T) {
+	t.Helper()
+	resetMigrations(t)
+	RegisterMigration(0, "move db_host to db.host", func(m map[string]any) error {
+		if host, ok := m["db_host"]; ok {
+			m["db"] = map[string]any{"host": host}
+			delete(m, "db_host")
+		}
+		return nil
+	})
+	RegisterMigration(1, "timeout with unit", func(m map[string]any) error {
+		if sec, ok := m["timeout"].(int); ok {
+			m["timeout"] = strconv.Itoa(sec) + "s"
+		}
+		return nil
+	})
+}
+
+func TestMigrate(t *testing.T) {
+	registerTestMigrations(t)
+	if LatestVersion() != 2 {
+		t.Fatalf("latest version = %d, want 2", LatestVersion())
+	}
+
+	m := map[string]any{"name": "app", "db_host": "db", "timeout": 5}
+	report, err := Migrate(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.From != 0 || report.To != 2 || len(report.Applied) != 2 || report.Applied[0] != "0 -> 1: move db_host to db.host" {
+		t.Errorf("report = %+v", report)
+	}
+	want := []Change{
+		{Key: "db.host", Op: "added", New: "db"},
+		{Key: "db_host", Op: "removed", Old: "db"},
+		{Key: "timeout", Op: "changed", Old: 5, New: "5s"},
+		{Key: "version", Op: "added", New: 2},
+	}
+	if len(report.Changes) != len(want) {
+		t.Fatalf("changes = %+v", report.Changes)
+	}
+	for i, c := range want {
+		if report.Changes[i] != c {
+			t.Errorf("change %d = %+v, want %+v", i, report.Changes[i], c)
+		}
+	}
+
+	// 已是最新版本时不做任何修改
+	report, err = Migrate(map[string]any{"version": 2, "db_host": "db"})
+	if err != nil || len(report.Applied) != 0 || report.From != 2 || report.To != 2 {
+		t.Errorf("up to date report = %+v, %v", report, err)
+	}
+}
+
+func TestMigrateErrors(t *testing.T) {
+	registerTestMigrations(t)
+	failed := errors.New("failed")
+	RegisterMigration(3, "broken", func(m map[string]any) error { return failed })
+
+	cases := []struct {
+		name string
+		m    map[string]any
+		msg  string
+	}{
+		{"invalid version", map[string]any{"version": "two"}, "invalid version"},
+		{"newer version", map[string]any{"version": 9}, "newer than the latest supported version 4"},
+		{"gap", map[string]any{"version": 1}, "no migration registered from version 2"},
+		{"migration error", map[string]any{"version": 3}, "migrate from version 3: failed"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := Migrate(c.m); err == nil || !strings.Contains(err.Error(), c.msg) {
+				t.Errorf("error = %v, want one containing %q", err, c.msg)
+			}
+		})
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a migration twice should panic")
+		}
+	}()
+	RegisterMigration(0, "again", func(m map[string]any) error { return nil })
+}
+
+func TestLoadMigrates(t *testing.T) {
+	registerTestMigrations(t)
+	var cfg struct {
+		Timeout time.Duration
+		DB      struct {
+			Host string
+		}
+	}
+	if _, err := LoadMap(map[string]any{"db_host": "db", "timeout": 5}, &cfg, WithStrict()); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DB.Host != "db" || cfg.Timeout != 5*time.Second {
+		t.Errorf("got %+v", cfg)
+	}
+}
+
+func TestMigrateFile(t *testing.T) {
+	registerTestMigrations(t)
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte("db_host: db\ntimeout: 5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MigrateFile(file, false); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(file); string(data) != "db_host: db\ntimeout: 5\n" {
+		t.Errorf("dry run modified the file:\n%s", data)
+	}
+
+	report, err := MigrateFile(file, true)
+	if err != nil || report.To != 2 {
+		t.Fatalf("report = %+v, %v", report, err)
+	}
+	m, err := parseConfig(mustRead(t, file), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["version"] != 2 || m["timeout"] != "5s" || m["db"].(map[string]any)["host"] != "db" {
+		t.Errorf("migrated file = %v", m)
+	}
+}
+
+func mustRead(t *testing.T, file string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
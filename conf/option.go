@@ -0,0 +1,96 @@
+package conf
+
+import (
+	"io"
+	"os"
+)
+
+type options struct {
+	flags     bool
+	args      []string
+	output    io.Writer
+	schema    bool
+	validate  bool
+	strict    bool
+	confDir   string
+	profile   string
+	sources   *map[string]string
+	noEnvPath bool
+}
+
+// Option Load 的可选配置
+type Option func(o *options)
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		output: os.Stderr,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithFlags 根据配置结构体生成命令行参数并解析 args，命令行参数的优先级最高
+func WithFlags(args []string) Option {
+	return func(o *options) {
+		o.flags = true
+		o.args = args
+	}
+}
+
+// WithOutput 设置帮助信息等输出的位置，默认 os.Stderr
+func WithOutput(w io.Writer) Option {
+	return func(o *options) {
+		o.output = w
+	}
+}
+
+// WithSchemaValidation 在 Unmarshal 之前按配置结构体生成的 JSON Schema 校验配置内容
+func WithSchemaValidation() Option {
+	return func(o *options) {
+		o.schema = true
+	}
+}
+
+// WithValidate 在 Unmarshal 之后按 validate 标签（required、min、max、oneof）校验配置结构体
+func WithValidate() Option {
+	return func(o *options) {
+		o.validate = true
+	}
+}
+
+// WithStrict 严格模式，配置中存在无法对应到结构体字段的 key 时返回错误
+func WithStrict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// WithConfDir 在主配置之后按文件名字典序合并本地目录（如 conf.d/）中的所有配置文件
+func WithConfDir(dir string) Option {
+	return func(o *options) {
+		o.confDir = dir
+	}
+}
+
+// WithProfile 指定 profile，优先于 --profile 和环境变量 ENV
+func WithProfile(name string) Option {
+	return func(o *options) {
+		o.profile = name
+	}
+}
+
+// WithProvenance 加载完成后将各个 key 的来源（flag、env、file、default、zero）写入 sources，可用于 DumpSources
+func WithProvenance(sources *map[string]string) Option {
+	return func(o *options) {
+		o.sources = sources
+	}
+}
+
+// WithoutEnvPath Load 只读取指定的文件，忽略环境变量 CONF_PATH，用于 lint 等命令行工具
+func WithoutEnvPath() Option {
+	return func(o *options) {
+		o.noEnvPath = true
+	}
+}
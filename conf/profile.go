@@ -0,0 +1,101 @@
+package conf
+
+import (
+	"os"
+	"sync"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// 内置的 profile，也可以使用任意自定义名称
+const (
+	ProfileDev     = "dev"
+	ProfileTest    = "test"
+	ProfileStaging = "staging"
+	ProfileProd    = "prod"
+)
+
+const (
+	// EnvProfile 选择 profile 的环境变量
+	EnvProfile = "ENV"
+	// profilesKey 配置文件中按 profile 覆盖配置的块，如 profiles.prod.db.maxopenconn
+	profilesKey = "profiles"
+	// profileFlag 开启 WithFlags 时用于选择 profile 的命令行参数
+	profileFlag = "profile"
+)
+
+var (
+	profileMu sync.RWMutex
+	profile   string
+)
+
+// Profile 返回当前生效的 profile：依次为 SetProfile 设置的值、环境变量 ENV，默认 dev。
+// log 等组件统一从这里读取运行环境
+func Profile() string {
+	profileMu.RLock()
+	defer profileMu.RUnlock()
+	if profile != "" {
+		return profile
+	}
+	if env := os.Getenv(EnvProfile); env != "" {
+		return env
+	}
+	return ProfileDev
+}
+
+// SetProfile 设置当前生效的 profile，WithProfile 或 --profile 指定时 Load 会自动调用。
+// 同时写入环境变量 ENV，log 等不依赖 conf 的组件读取 ENV 也能得到相同的 profile
+func SetProfile(name string) {
+	profileMu.Lock()
+	defer profileMu.Unlock()
+	profile = name
+	if name != "" {
+		_ = os.Setenv(EnvProfile, name)
+	}
+}
+
+// IsProd 是否为生产环境
+func IsProd() bool {
+	return Profile() == ProfileProd
+}
+
+// resolveProfile 确定本次加载使用的 profile：WithProfile 优先，其次 --profile，最后是 Profile()。
+// 显式指定时同步到 SetProfile，使其他组件看到相同的 profile
+func resolveProfile(o *options, fs *pflag.FlagSet) string {
+	name := o.profile
+	if name == "" && fs != nil && fs.Changed(profileFlag) {
+		name, _ = fs.GetString(profileFlag)
+	}
+	if name == "" {
+		return Profile()
+	}
+	SetProfile(name)
+	return name
+}
+
+// applyProfile 将 profiles 块中当前 profile 的配置合并到根上，并移除 profiles 块
+func applyProfile(cfg *viper.Viper, name string) (*viper.Viper, error) {
+	if !cfg.IsSet(profilesKey) {
+		return cfg, nil
+	}
+	raw := cfg.AllSettings()
+	profiles, _ := toStringMap(raw[profilesKey])
+	delete(raw, profilesKey)
+	if overlay, ok := toStringMap(profiles[name]); ok {
+		mergeMap(raw, overlay)
+	}
+	return rebuild(cfg, raw)
+}
+
+// rebuild 用处理后的原始配置创建新的 viper，保留配置文件路径
+func rebuild(cfg *viper.Viper, raw map[string]any) (*viper.Viper, error) {
+	fresh := viper.New()
+	if file := cfg.ConfigFileUsed(); file != "" {
+		fresh.SetConfigFile(file)
+	}
+	if err := fresh.MergeConfigMap(raw); err != nil {
+		return nil, err
+	}
+	return fresh, nil
+}
@@ -0,0 +1,119 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useProfile 测试结束后恢复 SetProfile 和环境变量 ENV
+func useProfile(t *testing.T, env string) {
+	t.Helper()
+	t.Setenv(EnvProfile, env)
+	t.Cleanup(func() { SetProfile("") })
+}
+
+type profileConfig struct {
+	Name string
+	DB   struct {
+		Host        string
+		MaxOpenConn int
+	}
+}
+
+func TestProfileOverlay(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `
+name: app
+db:
+  host: localhost
+  maxopenconn: 10
+profiles:
+  prod:
+    db:
+      maxopenconn: 100
+  test:
+    name: app-test
+`,
+		"config.prod.yaml": "db:\n  host: prod-db\n",
+	})
+	file := filepath.Join(dir, "config.yaml")
+	cases := []struct {
+		profile string
+		name    string
+		host    string
+		conns   int
+	}{
+		{ProfileDev, "app", "localhost", 10},
+		{ProfileTest, "app-test", "localhost", 10},
+		{ProfileProd, "app", "prod-db", 100},
+	}
+	for _, c := range cases {
+		t.Run(c.profile, func(t *testing.T) {
+			useProfile(t, "")
+			var cfg profileConfig
+			if _, err := Load(file, &cfg, WithoutEnvPath(), WithProfile(c.profile), WithStrict()); err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Name != c.name || cfg.DB.Host != c.host || cfg.DB.MaxOpenConn != c.conns {
+				t.Errorf("got %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestResolveProfile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml":         "name: base\n",
+		"config.prod.yaml":    "name: prod\n",
+		"config.staging.yaml": "name: staging\n",
+		"config.test.yaml":    "name: test\n",
+	})
+	file := filepath.Join(dir, "config.yaml")
+	cases := []struct {
+		name    string
+		env     string
+		opts    []Option
+		profile string
+		want    string
+	}{
+		{"default dev", "", nil, ProfileDev, "base"},
+		{"env", ProfileTest, nil, ProfileTest, "test"},
+		{"flag over env", ProfileTest, []Option{WithFlags([]string{"--profile", "staging"})}, ProfileStaging, "staging"},
+		{"option over flag", ProfileTest, []Option{WithFlags([]string{"--profile=staging"}), WithProfile(ProfileProd)}, ProfileProd, "prod"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			useProfile(t, c.env)
+			var cfg struct{ Name string }
+			if _, err := Load(file, &cfg, append(c.opts, WithoutEnvPath())...); err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Name != c.want {
+				t.Errorf("name = %q, want %q", cfg.Name, c.want)
+			}
+			// 选择的 profile 对 Profile() 可见，显式指定时同步到 ENV 供 log 等组件读取
+			if Profile() != c.profile {
+				t.Errorf("Profile() = %q, want %q", Profile(), c.profile)
+			}
+			if len(c.opts) > 0 && os.Getenv(EnvProfile) != c.profile {
+				t.Errorf("ENV = %q, want %q", os.Getenv(EnvProfile), c.profile)
+			}
+		})
+	}
+}
+
+func TestProfile(t *testing.T) {
+	useProfile(t, "")
+	if Profile() != ProfileDev || IsProd() {
+		t.Errorf("default profile = %q", Profile())
+	}
+	t.Setenv(EnvProfile, ProfileStaging)
+	if Profile() != ProfileStaging {
+		t.Errorf("profile from ENV = %q", Profile())
+	}
+	SetProfile(ProfileProd)
+	if Profile() != ProfileProd || !IsProd() || os.Getenv(EnvProfile) != ProfileProd {
+		t.Errorf("after SetProfile profile = %q, ENV = %q", Profile(), os.Getenv(EnvProfile))
+	}
+}
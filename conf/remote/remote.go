@@ -0,0 +1,231 @@
+// Package remote 远程 KV 配置来源，HTTP 协议兼容 Consul KV API，
+// 支持长轮询监听变化，并将最后一次成功读取的配置缓存到本地用于冷启动
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/xybingbing/pkg/conf"
+)
+
+// ErrNotFound 远程 key 不存在
+var ErrNotFound = errors.New("remote: key not found")
+
+// Source 远程 KV 配置来源，实现 conf.Source
+type Source struct {
+	endpoint  string
+	key       string
+	format    string
+	token     string
+	cacheFile string
+	waitTime  time.Duration
+	client    *http.Client
+	index     atomic.Uint64
+}
+
+var _ conf.Source = (*Source)(nil)
+
+type Option func(s *Source)
+
+// New 创建远程配置来源，endpoint 如 http://127.0.0.1:8500，key 如 config/app
+func New(endpoint, key string, opts ...Option) *Source {
+	s := &Source{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		key:      strings.Trim(key, "/"),
+		format:   "yaml",
+		waitTime: 5 * time.Minute,
+		client:   http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// WithFormat 配置内容的格式，默认 yaml
+func WithFormat(format string) Option {
+	return func(s *Source) {
+		s.format = format
+	}
+}
+
+// WithToken 访问令牌，通过 X-Consul-Token 请求头传递
+func WithToken(token string) Option {
+	return func(s *Source) {
+		s.token = token
+	}
+}
+
+// WithCacheFile 本地缓存文件，远程不可用时使用最后一次成功读取的配置
+func WithCacheFile(path string) Option {
+	return func(s *Source) {
+		s.cacheFile = path
+	}
+}
+
+// WithWaitTime 长轮询的最长等待时间，默认 5 分钟；服务端按秒计时，不足 1 秒按 1 秒处理
+func WithWaitTime(wait time.Duration) Option {
+	return func(s *Source) {
+		s.waitTime = max(wait, time.Second)
+	}
+}
+
+// WithHTTPClient 自定义 http.Client
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Source) {
+		s.client = client
+	}
+}
+
+// Read 读取远程配置，失败时回退到本地缓存
+func (s *Source) Read(cfg *viper.Viper) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	data, index, err := s.get(ctx, 0, 0)
+	if err != nil {
+		cached, cacheErr := s.readCache()
+		if cacheErr != nil {
+			return err
+		}
+		log.Printf("remote: read %s failed, use cache %s: %v", s.key, s.cacheFile, err)
+		data = cached
+	} else {
+		s.index.Store(index)
+		if err = s.writeCache(data); err != nil {
+			log.Printf("remote: write cache %s: %v", s.cacheFile, err)
+		}
+	}
+	cfg.SetConfigType(s.format)
+	return cfg.ReadConfig(bytes.NewReader(data))
+}
+
+// Watch 通过长轮询监听 key 的变化，请求失败时退避重试
+func (s *Source) Watch(ctx context.Context, notify func()) error {
+	backoff := time.Second
+	for {
+		index := s.index.Load()
+		_, newIndex, err := s.get(ctx, index, s.waitTime)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			log.Printf("remote: watch %s: %v, retry in %s", s.key, err, backoff)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(backoff):
+			}
+			if backoff < 30*time.Second {
+				backoff *= 2
+			}
+			continue
+		}
+		backoff = time.Second
+		if newIndex == index {
+			continue
+		}
+		// index 变小说明服务端重建过数据，重新从头开始
+		if newIndex < index {
+			newIndex = 0
+		}
+		s.index.Store(newIndex)
+		notify()
+	}
+}
+
+type kvPair struct {
+	Key         string
+	Value       []byte
+	ModifyIndex uint64
+}
+
+// get 读取 key 的值，index 大于 0 时为阻塞查询，直到值变化或等待超时
+func (s *Source) get(ctx context.Context, index uint64, wait time.Duration) ([]byte, uint64, error) {
+	query := url.Values{}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", fmt.Sprintf("%ds", int(wait.Seconds())))
+	}
+	u := s.endpoint + "/v1/kv/" + s.key
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if s.token != "" {
+		req.Header.Set("X-Consul-Token", s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, 0, ErrNotFound
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, 0, fmt.Errorf("remote: unexpected status %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	var pairs []kvPair
+	if err = json.NewDecoder(resp.Body).Decode(&pairs); err != nil {
+		return nil, 0, err
+	}
+	if len(pairs) == 0 {
+		return nil, 0, ErrNotFound
+	}
+	newIndex, err := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if err != nil {
+		newIndex = pairs[0].ModifyIndex
+	}
+	return pairs[0].Value, newIndex, nil
+}
+
+func (s *Source) readCache() ([]byte, error) {
+	if s.cacheFile == "" {
+		return nil, os.ErrNotExist
+	}
+	return os.ReadFile(s.cacheFile)
+}
+
+// writeCache 先写临时文件再重命名，避免进程中断留下不完整的缓存
+func (s *Source) writeCache(data []byte) error {
+	if s.cacheFile == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.cacheFile), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.cacheFile), filepath.Base(s.cacheFile)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.cacheFile)
+}
@@ -0,0 +1,174 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xybingbing/pkg/conf"
+)
+
+type appConfig struct {
+	Name string
+	Port int
+}
+
+func TestLoad(t *testing.T) {
+	srv := NewServer()
+	srv.Put("config/app", []byte("name: remote\nport: 80\n"))
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	cache := filepath.Join(t.TempDir(), "cache", "app.yaml")
+	var cfg appConfig
+	if _, err := conf.LoadSource(New(ts.URL, "/config/app/", WithCacheFile(cache)), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "remote" || cfg.Port != 80 {
+		t.Errorf("got %+v", cfg)
+	}
+	if data, err := os.ReadFile(cache); err != nil || string(data) != "name: remote\nport: 80\n" {
+		t.Errorf("cache = %q, %v", data, err)
+	}
+
+	if _, err := conf.LoadSource(New(ts.URL, "config/missing"), &cfg); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing key error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestColdStartFromCache(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	ts.Close() // 远程不可用
+
+	cache := filepath.Join(t.TempDir(), "app.json")
+	if err := os.WriteFile(cache, []byte(`{"name": "cached", "port": 81}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var cfg appConfig
+	if _, err := conf.LoadSource(New(ts.URL, "config/app", WithFormat("json"), WithCacheFile(cache)), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "cached" || cfg.Port != 81 {
+		t.Errorf("got %+v", cfg)
+	}
+
+	if _, err := conf.LoadSource(New(ts.URL, "config/app"), &cfg); err == nil {
+		t.Error("remote down without cache should fail")
+	}
+}
+
+func TestWatchReload(t *testing.T) {
+	srv := NewServer()
+	srv.Put("config/app", []byte("name: v1\n"))
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	var cfg appConfig
+	w, err := conf.Watch(New(ts.URL, "config/app", WithWaitTime(time.Second)), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	changed := make(chan string, 1)
+	w.OnChange(func(v any) { changed <- v.(*appConfig).Name })
+
+	srv.Put("config/app", []byte("name: v2\n"))
+	select {
+	case name := <-changed:
+		if name != "v2" {
+			t.Errorf("reloaded name = %q, want v2", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload after the remote value changed")
+	}
+	if got := w.Value().(*appConfig); got.Name != "v2" {
+		t.Errorf("value = %+v", got)
+	}
+}
+
+// TestWatchIndexReset 服务端 index 变小（数据重建）时重新从头读取
+func TestWatchIndexReset(t *testing.T) {
+	var mu sync.Mutex
+	var indexes []string
+	reset := false
+	blocked := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		index := r.URL.Query().Get("index")
+		mu.Lock()
+		indexes = append(indexes, index)
+		current := 10
+		switch {
+		case index == "10":
+			reset = true
+			current = 3
+		case reset:
+			current = 3
+		}
+		mu.Unlock()
+		if index == "3" {
+			close(blocked)
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("X-Consul-Index", fmt.Sprint(current))
+		fmt.Fprintf(w, `[{"Key": "config/app", "Value": "bmFtZTogYQ==", "ModifyIndex": %d}]`, current)
+	}))
+	defer ts.Close()
+
+	src := New(ts.URL, "config/app", WithWaitTime(time.Millisecond))
+	var cfg appConfig
+	if _, err := conf.LoadSource(src, &cfg); err != nil || cfg.Name != "a" {
+		t.Fatalf("load = %+v, %v", cfg, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	notified := make(chan struct{}, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = src.Watch(ctx, func() { notified <- struct{}{} })
+	}()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-notified:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("notify %d not received", i)
+		}
+	}
+	select {
+	case <-blocked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch did not continue with the new index")
+	}
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	// 首次读取、阻塞查询得到更小的 index、从头读取、以新的 index 继续阻塞查询
+	want := []string{"", "10", "", "3"}
+	if fmt.Sprint(indexes) != fmt.Sprint(want) {
+		t.Errorf("requested indexes %q, want %q", indexes, want)
+	}
+}
+
+func TestWithWaitTime(t *testing.T) {
+	for _, c := range []struct {
+		wait, want time.Duration
+	}{
+		{0, time.Second},
+		{500 * time.Millisecond, time.Second},
+		{90 * time.Second, 90 * time.Second},
+	} {
+		if got := New("http://localhost", "k", WithWaitTime(c.wait)).waitTime; got != c.want {
+			t.Errorf("WithWaitTime(%s) = %s, want %s", c.wait, got, c.want)
+		}
+	}
+}
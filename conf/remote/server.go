@@ -0,0 +1,121 @@
+package remote
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server 内存中的 KV 服务，实现与 Source 相同的 HTTP 协议（GET 支持阻塞查询、PUT、DELETE），
+// 用于测试和本地开发，可配合 httptest.NewServer 使用
+type Server struct {
+	mu      sync.Mutex
+	index   uint64
+	entries map[string]kvPair
+	changed chan struct{}
+}
+
+func NewServer() *Server {
+	return &Server{
+		entries: map[string]kvPair{},
+		changed: make(chan struct{}),
+	}
+}
+
+// Put 写入 key 并唤醒等待中的阻塞查询
+func (s *Server) Put(key string, value []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.index++
+	key = strings.Trim(key, "/")
+	s.entries[key] = kvPair{Key: key, Value: value, ModifyIndex: s.index}
+	s.notify()
+}
+
+// Delete 删除 key
+func (s *Server) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.index++
+	delete(s.entries, strings.Trim(key, "/"))
+	s.notify()
+}
+
+// notify 关闭并替换 changed，唤醒所有等待者，调用方需持有锁
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key, ok := strings.CutPrefix(r.URL.Path, "/v1/kv/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	key = strings.Trim(key, "/")
+	switch r.Method {
+	case http.MethodGet:
+		s.get(w, r, key)
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.Put(key, body)
+		_, _ = w.Write([]byte("true"))
+	case http.MethodDelete:
+		s.Delete(key)
+		_, _ = w.Write([]byte("true"))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, key string) {
+	index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+	wait, err := time.ParseDuration(r.URL.Query().Get("wait"))
+	if err != nil || wait <= 0 {
+		wait = 5 * time.Minute
+	}
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+
+	for {
+		s.mu.Lock()
+		entry, found := s.entries[key]
+		current, changed := entry.ModifyIndex, s.changed
+		if !found {
+			current = s.index
+		}
+		s.mu.Unlock()
+
+		if index == 0 || current != index {
+			s.write(w, entry, found, current)
+			return
+		}
+		select {
+		case <-changed:
+		case <-timeout.C:
+			s.write(w, entry, found, current)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) write(w http.ResponseWriter, entry kvPair, found bool, index uint64) {
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(index, 10))
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode([]kvPair{entry})
+}
@@ -0,0 +1,395 @@
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Schema JSON Schema（draft 2020-12）的子集，足够描述配置结构体
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Secret               bool               `json:"x-secret,omitempty"`
+}
+
+// NewSchema 根据配置结构体生成 JSON Schema，描述取自 usage 标签，
+// 默认值取自 default 标签，约束取自 validate 标签，secret:"true" 的字段标记 x-secret
+func NewSchema(v any) (*Schema, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errors.New("conf: schema requires a struct")
+	}
+	s, err := typeSchema(t, map[reflect.Type]bool{})
+	if err != nil {
+		return nil, err
+	}
+	s.Schema = "https://json-schema.org/draft/2020-12/schema"
+	s.Title = t.Name()
+	return s, nil
+}
+
+// JSONSchema 生成格式化后的 JSON Schema，可直接给编辑器做自动补全
+func JSONSchema(v any) ([]byte, error) {
+	s, err := NewSchema(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(s, "", "  ")
+}
+
+func typeSchema(t reflect.Type, visiting map[reflect.Type]bool) (*Schema, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == durationType || t == confDurType:
+		return &Schema{Type: "string", Format: "duration"}, nil
+	case t == byteSizeType:
+		return &Schema{Type: "string", Format: "byte-size"}, nil
+	case t == percentType:
+		return &Schema{Type: "string", Format: "percent"}, nil
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}, nil
+	case t == urlType:
+		return &Schema{Type: "string", Format: "uri"}, nil
+	case !isNested(t) && reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return &Schema{Type: "string"}, nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := typeSchema(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		elem, err := typeSchema(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: elem}, nil
+	case reflect.Struct:
+		return structSchema(t, visiting)
+	}
+	// interface 等类型不做限制
+	return &Schema{}, nil
+}
+
+func structSchema(t reflect.Type, visiting map[reflect.Type]bool) (*Schema, error) {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	if visiting[t] {
+		return s, nil
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() || sf.Tag.Get("mapstructure") == "-" {
+			continue
+		}
+		name, squash := keyName(sf)
+		fs, err := typeSchema(sf.Type, visiting)
+		if err != nil {
+			return nil, err
+		}
+		if squash {
+			for k, p := range fs.Properties {
+				s.Properties[k] = p
+			}
+			s.Required = append(s.Required, fs.Required...)
+			continue
+		}
+		r, err := parseRules(sf.Tag.Get("validate"))
+		if err != nil {
+			return nil, fmt.Errorf("conf: %s.%s: %w", t.Name(), sf.Name, err)
+		}
+		fs.Description = sf.Tag.Get("usage")
+		fs.Secret = isSecret(sf)
+		def := sf.Tag.Get("default")
+		if def != "" && def != "-" && !isNested(sf.Type) {
+			fs.Default = schemaValue(fs.Type, def)
+		}
+		if r.required && fs.Default == nil {
+			s.Required = append(s.Required, name)
+		}
+		for _, item := range r.oneof {
+			fs.Enum = append(fs.Enum, schemaValue(fs.Type, item))
+		}
+		applyLimit(fs, r.min, true)
+		applyLimit(fs, r.max, false)
+		s.Properties[name] = fs
+	}
+	sort.Strings(s.Required)
+	return s, nil
+}
+
+// applyLimit 将 min/max 规则转为对应的 JSON Schema 约束
+func applyLimit(s *Schema, arg string, isMin bool) {
+	if arg == "" {
+		return
+	}
+	switch s.Type {
+	case "integer", "number":
+		if f, err := strconv.ParseFloat(arg, 64); err == nil {
+			if isMin {
+				s.Minimum = &f
+			} else {
+				s.Maximum = &f
+			}
+		}
+	case "string", "array":
+		n, err := strconv.Atoi(arg)
+		if err != nil || s.Format != "" {
+			return
+		}
+		switch {
+		case s.Type == "string" && isMin:
+			s.MinLength = &n
+		case s.Type == "string":
+			s.MaxLength = &n
+		case isMin:
+			s.MinItems = &n
+		default:
+			s.MaxItems = &n
+		}
+	}
+}
+
+// schemaValue 将标签中的字符串转为 schema 类型对应的 JSON 值
+func schemaValue(typ, s string) any {
+	switch typ {
+	case "boolean":
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	case "integer":
+		if n, err := strconv.ParseInt(s, 0, 64); err == nil {
+			return n
+		}
+	case "number":
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case "array":
+		return strings.Split(s, ",")
+	}
+	return s
+}
+
+//================================================================================
+
+// validateSchema 在 Unmarshal 之前按 schema 校验配置内容。
+// 环境变量和命令行参数以字符串形式出现，能转换为目标类型的字符串视为合法
+func validateSchema(cfg *viper.Viper, v any) error {
+	s, err := NewSchema(v)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	s.validate("", cfg.AllSettings(), &errs)
+	return errors.Join(errs...)
+}
+
+func (s *Schema) validate(path string, val any, errs *[]error) {
+	fail := func(format string, args ...any) {
+		name := path
+		if name == "" {
+			name = "(root)"
+		}
+		*errs = append(*errs, fmt.Errorf("conf: %s: %s", name, fmt.Sprintf(format, args...)))
+	}
+	if val == nil {
+		return
+	}
+	switch s.Type {
+	case "object":
+		m, ok := toStringMap(val)
+		if !ok {
+			fail("expected object, got %T", val)
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := m[name]; !ok {
+				fail("missing required key %q", name)
+			}
+		}
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			prop, ok := s.Properties[strings.ToLower(k)]
+			if !ok {
+				prop = s.AdditionalProperties
+			}
+			if prop != nil {
+				prop.validate(joinKey(path, k), m[k], errs)
+			}
+		}
+		return
+	case "array":
+		list, ok := val.([]any)
+		if !ok {
+			if _, isString := val.(string); !isString {
+				fail("expected array, got %T", val)
+			}
+			return
+		}
+		if s.MinItems != nil && len(list) < *s.MinItems {
+			fail("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(list) > *s.MaxItems {
+			fail("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range list {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+			}
+		}
+		return
+	case "boolean":
+		if _, ok := val.(bool); !ok {
+			if str, isString := val.(string); !isString || !isBool(str) {
+				fail("expected boolean, got %v", val)
+				return
+			}
+		}
+	case "integer", "number":
+		n, ok := toFloat(val)
+		if !ok || (s.Type == "integer" && n != float64(int64(n))) {
+			fail("expected %s, got %v", s.Type, val)
+			return
+		}
+		if s.Minimum != nil && n < *s.Minimum {
+			fail("must be >= %v, got %v", *s.Minimum, val)
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			fail("must be <= %v, got %v", *s.Maximum, val)
+		}
+	case "string":
+		switch val.(type) {
+		case map[string]any, []any:
+			fail("expected string, got %T", val)
+			return
+		}
+		str := fmt.Sprint(val)
+		if s.Format == "duration" {
+			if _, err := time.ParseDuration(str); err != nil {
+				fail("invalid duration %q", str)
+			}
+		}
+		if s.MinLength != nil && len(str) < *s.MinLength {
+			fail("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && len(str) > *s.MaxLength {
+			fail("must be at most %d characters", *s.MaxLength)
+		}
+	}
+	if len(s.Enum) > 0 {
+		str := fmt.Sprint(val)
+		for _, e := range s.Enum {
+			if fmt.Sprint(e) == str {
+				return
+			}
+		}
+		fail("must be one of %v, got %v", s.Enum, val)
+	}
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func toStringMap(val any) (map[string]any, bool) {
+	switch m := val.(type) {
+	case map[string]any:
+		return m, true
+	case map[any]any:
+		out := make(map[string]any, len(m))
+		for k, v := range m {
+			out[fmt.Sprint(k)] = v
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+func toFloat(val any) (float64, bool) {
+	switch n := val.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func isBool(s string) bool {
+	_, err := strconv.ParseBool(s)
+	return err == nil
+}
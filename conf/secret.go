@@ -0,0 +1,250 @@
+package conf
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// EnvSecretKey 解密 enc: 配置值的密钥（base64 或 hex 编码，16/24/32 字节）
+	EnvSecretKey = "CONF_SECRET_KEY"
+	// EnvSecretKeyFile 存放密钥的文件路径，未设置 CONF_SECRET_KEY 时使用
+	EnvSecretKeyFile = "CONF_SECRET_KEY_FILE"
+
+	encPrefix = "enc:"
+	// Mask 脱敏后的占位符
+	Mask = "******"
+)
+
+// secretRef 匹配 ${env:VAR} 与 ${file:/path} 引用
+var secretRef = regexp.MustCompile(`\$\{(env|file):([^}]+)}`)
+
+// resolveSecrets 解析配置中的密钥引用和加密值
+func resolveSecrets(cfg *viper.Viper) error {
+	var key []byte
+	for _, k := range cfg.AllKeys() {
+		switch val := cfg.Get(k).(type) {
+		case string:
+			resolved, err := resolveSecret(val, &key)
+			if err != nil {
+				return fmt.Errorf("conf: %s: %w", k, err)
+			}
+			if resolved != val {
+				cfg.Set(k, resolved)
+			}
+		case []any:
+			changed := false
+			list := make([]any, len(val))
+			for i, item := range val {
+				list[i] = item
+				s, ok := item.(string)
+				if !ok {
+					continue
+				}
+				resolved, err := resolveSecret(s, &key)
+				if err != nil {
+					return fmt.Errorf("conf: %s[%d]: %w", k, i, err)
+				}
+				if resolved != s {
+					list[i] = resolved
+					changed = true
+				}
+			}
+			if changed {
+				cfg.Set(k, list)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveSecret 解析单个值，key 在第一次遇到加密值时才加载
+func resolveSecret(val string, key *[]byte) (string, error) {
+	if strings.HasPrefix(val, encPrefix) {
+		if *key == nil {
+			k, err := SecretKey()
+			if err != nil {
+				return "", err
+			}
+			*key = k
+		}
+		return Decrypt(*key, val)
+	}
+	if !strings.Contains(val, "${") {
+		return val, nil
+	}
+	var resolveErr error
+	resolved := secretRef.ReplaceAllStringFunc(val, func(ref string) string {
+		m := secretRef.FindStringSubmatch(ref)
+		switch m[1] {
+		case "env":
+			v, ok := os.LookupEnv(m[2])
+			if !ok && resolveErr == nil {
+				resolveErr = fmt.Errorf("environment variable %s not set", m[2])
+			}
+			return v
+		default:
+			b, err := os.ReadFile(m[2])
+			if err != nil && resolveErr == nil {
+				resolveErr = err
+			}
+			return strings.TrimRight(string(b), "\r\n")
+		}
+	})
+	return resolved, resolveErr
+}
+
+// SecretKey 从环境变量 CONF_SECRET_KEY 或 CONF_SECRET_KEY_FILE 指向的文件读取密钥
+func SecretKey() ([]byte, error) {
+	raw, ok := os.LookupEnv(EnvSecretKey)
+	if !ok {
+		path := os.Getenv(EnvSecretKeyFile)
+		if path == "" {
+			return nil, fmt.Errorf("secret key not found, set %s or %s", EnvSecretKey, EnvSecretKeyFile)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		raw = string(b)
+	}
+	return ParseSecretKey(raw)
+}
+
+// ParseSecretKey 解析 base64 或 hex 编码的 AES 密钥
+func ParseSecretKey(raw string) ([]byte, error) {
+	raw = strings.TrimSpace(raw)
+	for _, decode := range []func(string) ([]byte, error){hex.DecodeString, base64.StdEncoding.DecodeString, base64.RawURLEncoding.DecodeString} {
+		if key, err := decode(raw); err == nil && validKeySize(len(key)) {
+			return key, nil
+		}
+	}
+	return nil, errors.New("secret key must be 16, 24 or 32 bytes encoded as base64 or hex")
+}
+
+func validKeySize(n int) bool {
+	return n == 16 || n == 24 || n == 32
+}
+
+// Encrypt 使用 AES-GCM 加密，返回可直接写入配置文件的 enc: 值
+func Encrypt(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 解密 Encrypt 生成的 enc: 值
+func Decrypt(key []byte, value string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encPrefix))
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value: %w", err)
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("invalid encrypted value: too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", errors.New("decrypt failed: wrong key or corrupted value")
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+//================================================================================
+
+// isSecret 判断字段是否标记了 secret:"true"
+func isSecret(f reflect.StructField) bool {
+	return f.Tag.Get("secret") == "true"
+}
+
+// Redact 返回 v 的副本，其中标记了 secret:"true" 的字段被替换为 Mask，用于打印或写日志
+func Redact(v any) any {
+	if v == nil {
+		return nil
+	}
+	src := reflect.ValueOf(v)
+	dst := reflect.New(src.Type()).Elem()
+	dst.Set(src)
+	redact(dst)
+	return dst.Interface()
+}
+
+func redact(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || !hasSecret(v.Type().Elem()) {
+			return
+		}
+		cp := reflect.New(v.Type().Elem())
+		cp.Elem().Set(v.Elem())
+		redact(cp.Elem())
+		v.Set(cp)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := v.Field(i)
+			if !f.CanSet() {
+				continue
+			}
+			if isSecret(t.Field(i)) {
+				maskValue(f)
+				continue
+			}
+			redact(f)
+		}
+	}
+}
+
+// maskValue 将敏感字段替换为占位符，非字符串类型置为零值
+func maskValue(v reflect.Value) {
+	if v.IsZero() {
+		return
+	}
+	if v.Kind() == reflect.String {
+		v.SetString(Mask)
+		return
+	}
+	v.Set(reflect.Zero(v.Type()))
+}
+
+// hasSecret 判断类型中是否包含敏感字段，避免无意义的深拷贝
+func hasSecret(t reflect.Type) bool {
+	found := false
+	walkFields(t, func(f field) bool {
+		if isSecret(f.Field) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,134 @@
+package conf
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestEncryptRoundTrip(t *testing.T) {
+	for _, plaintext := range []string{"", "secret", "p@ss:wörd;with=symbols"} {
+		enc, err := Encrypt(testKey, plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(enc, encPrefix) {
+			t.Fatalf("%q has no %s prefix", enc, encPrefix)
+		}
+		got, err := Decrypt(testKey, enc)
+		if err != nil || got != plaintext {
+			t.Errorf("Decrypt(Encrypt(%q)) = %q, %v", plaintext, got, err)
+		}
+	}
+	enc, _ := Encrypt(testKey, "secret")
+	if _, err := Decrypt([]byte("fedcba9876543210fedcba9876543210"), enc); err == nil {
+		t.Error("decrypt with a wrong key should fail")
+	}
+	if _, err := Decrypt(testKey, enc[:len(enc)-4]+"AAAA"); err == nil {
+		t.Error("decrypt of a corrupted value should fail")
+	}
+}
+
+func TestParseSecretKey(t *testing.T) {
+	cases := []struct {
+		raw string
+		ok  bool
+	}{
+		{hex.EncodeToString(testKey), true},
+		{base64.StdEncoding.EncodeToString(testKey), true},
+		{base64.RawURLEncoding.EncodeToString(testKey[:16]), true},
+		{" " + hex.EncodeToString(testKey[:24]) + "\n", true},
+		{hex.EncodeToString(testKey[:10]), false},
+		{"not a key", false},
+	}
+	for _, c := range cases {
+		if _, err := ParseSecretKey(c.raw); (err == nil) != c.ok {
+			t.Errorf("ParseSecretKey(%q) error = %v, want ok %v", c.raw, err, c.ok)
+		}
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "password")
+	if err := os.WriteFile(file, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	enc, err := Encrypt(testKey, "from-enc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONF_TEST_USER", "root")
+	t.Setenv(EnvSecretKey, hex.EncodeToString(testKey))
+
+	var cfg struct {
+		DSN      string
+		Password string
+		Token    string
+		Hosts    []string
+	}
+	_, err = LoadMap(map[string]any{
+		"dsn":      "${env:CONF_TEST_USER}:${file:" + file + "}@tcp(db:3306)/app",
+		"password": "${file:" + file + "}",
+		"token":    enc,
+		"hosts":    []any{"${env:CONF_TEST_USER}.local", "static"},
+	}, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DSN != "root:from-file@tcp(db:3306)/app" || cfg.Password != "from-file" || cfg.Token != "from-enc" {
+		t.Errorf("got %+v", cfg)
+	}
+	if strings.Join(cfg.Hosts, ",") != "root.local,static" {
+		t.Errorf("hosts = %v", cfg.Hosts)
+	}
+
+	for name, m := range map[string]map[string]any{
+		"missing env":  {"password": "${env:CONF_TEST_MISSING}"},
+		"missing file": {"password": "${file:" + filepath.Join(dir, "missing") + "}"},
+		"bad enc":      {"password": "enc:AAAA"},
+	} {
+		if _, err := LoadMap(m, &cfg); err == nil || !strings.Contains(err.Error(), "password") {
+			t.Errorf("%s: error = %v, want one naming the key", name, err)
+		}
+	}
+
+	t.Setenv(EnvSecretKey, "")
+	os.Unsetenv(EnvSecretKey)
+	if _, err := LoadMap(map[string]any{"token": enc}, &cfg); err == nil {
+		t.Error("enc: value without a secret key should fail")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	type db struct {
+		DSN      string
+		Password string `secret:"true"`
+	}
+	type config struct {
+		Name   string
+		APIKey string `secret:"true"`
+		Port   int    `secret:"true"`
+		Empty  string `secret:"true"`
+		DB     *db
+	}
+	cfg := &config{Name: "app", APIKey: "key", Port: 8080, DB: &db{DSN: "dsn", Password: "pass"}}
+	got := Redact(cfg).(*config)
+	if got.Name != "app" || got.APIKey != Mask || got.Port != 0 || got.Empty != "" {
+		t.Errorf("redacted %+v", got)
+	}
+	if got.DB.DSN != "dsn" || got.DB.Password != Mask {
+		t.Errorf("redacted db %+v", got.DB)
+	}
+	if cfg.APIKey != "key" || cfg.Port != 8080 || cfg.DB.Password != "pass" {
+		t.Errorf("original modified: %+v %+v", cfg, cfg.DB)
+	}
+	if Redact(nil) != nil {
+		t.Error("Redact(nil) should be nil")
+	}
+}
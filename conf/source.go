@@ -0,0 +1,98 @@
+package conf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// source 配置来源，负责将原始配置读入 viper，profile 为当前生效的 profile
+type source func(cfg *viper.Viper, profile string) error
+
+// configPath 环境变量 CONF_PATH 优先于代码中指定的路径
+func configPath(path string) string {
+	if envConf := os.Getenv("CONF_PATH"); envConf != "" {
+		return envConf
+	}
+	return path
+}
+
+// fileSource 读取 path 指定的文件，调用方负责处理 CONF_PATH
+func fileSource(path string) source {
+	return func(cfg *viper.Viper, profile string) error {
+		m, err := osFiles.readWithOverlay(path, profile)
+		if err != nil {
+			return err
+		}
+		cfg.SetConfigFile(path)
+		return cfg.MergeConfigMap(m)
+	}
+}
+
+func readerSource(r io.Reader, format string) source {
+	return func(cfg *viper.Viper, _ string) error {
+		if format == "" {
+			return errors.New("conf: config format is required")
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		m, err := parseConfig(data, format)
+		if err != nil {
+			return fmt.Errorf("conf: %w", err)
+		}
+		if _, ok := m[includeKey]; ok {
+			return errors.New("conf: include is only supported when loading from files")
+		}
+		return cfg.MergeConfigMap(m)
+	}
+}
+
+func fsSource(fsys fs.FS, name string) source {
+	return func(cfg *viper.Viper, profile string) error {
+		m, err := fsFiles(fsys).readWithOverlay(name, profile)
+		if err != nil {
+			return err
+		}
+		return cfg.MergeConfigMap(m)
+	}
+}
+
+func mapSource(m map[string]any) source {
+	return func(cfg *viper.Viper, _ string) error {
+		return cfg.MergeConfigMap(m)
+	}
+}
+
+// formatOf 根据文件扩展名得到配置格式，如 yaml、json、toml
+func formatOf(name string) string {
+	return strings.TrimPrefix(path.Ext(name), ".")
+}
+
+// LoadBytes 从内存数据加载配置，format 为 yaml、json、toml 等 viper 支持的格式
+func LoadBytes(data []byte, format string, v any, opts ...Option) (*viper.Viper, error) {
+	return load(readerSource(bytes.NewReader(data), format), v, opts...)
+}
+
+// LoadReader 从 io.Reader 加载配置
+func LoadReader(r io.Reader, format string, v any, opts ...Option) (*viper.Viper, error) {
+	return load(readerSource(r, format), v, opts...)
+}
+
+// LoadFS 从 fs.FS 加载配置，可用于 embed.FS 将默认配置打包进二进制，格式由扩展名决定
+func LoadFS(fsys fs.FS, name string, v any, opts ...Option) (*viper.Viper, error) {
+	return load(fsSource(fsys, name), v, opts...)
+}
+
+// LoadMap 从 map 加载配置
+func LoadMap(m map[string]any, v any, opts ...Option) (*viper.Viper, error) {
+	return load(mapSource(m), v, opts...)
+}
@@ -0,0 +1,117 @@
+package conf
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+type sourceConfig struct {
+	Name string
+	Port int `default:"80"`
+	DB   sourceDB
+}
+
+type sourceDB struct {
+	Host string
+	Port int
+}
+
+func TestLoadSources(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config.yaml":      {Data: []byte("include: base.yaml\nname: fs\ndb:\n  port: 3306\n")},
+		"base.yaml":        {Data: []byte("name: base\nport: 81\ndb:\n  host: base-host\n")},
+		"config.test.yaml": {Data: []byte("db:\n  port: 3307\n")},
+	}
+	cases := []struct {
+		name string
+		load func(v *sourceConfig) error
+		want sourceConfig
+	}{
+		{
+			name: "bytes yaml",
+			load: func(v *sourceConfig) error {
+				_, err := LoadBytes([]byte("name: bytes\ndb:\n  host: h\n"), "yaml", v)
+				return err
+			},
+			want: sourceConfig{Name: "bytes", Port: 80, DB: sourceDB{Host: "h"}},
+		},
+		{
+			name: "reader json",
+			load: func(v *sourceConfig) error {
+				_, err := LoadReader(strings.NewReader(`{"name": "reader", "port": 82}`), "json", v)
+				return err
+			},
+			want: sourceConfig{Name: "reader", Port: 82},
+		},
+		{
+			name: "fs with include",
+			load: func(v *sourceConfig) error {
+				_, err := LoadFS(fsys, "config.yaml", v, WithProfile(ProfileDev))
+				return err
+			},
+			want: sourceConfig{Name: "fs", Port: 81, DB: sourceDB{"base-host", 3306}},
+		},
+		{
+			name: "fs with profile overlay",
+			load: func(v *sourceConfig) error {
+				_, err := LoadFS(fsys, "config.yaml", v, WithProfile(ProfileTest))
+				return err
+			},
+			want: sourceConfig{Name: "fs", Port: 81, DB: sourceDB{"base-host", 3307}},
+		},
+		{
+			name: "map",
+			load: func(v *sourceConfig) error {
+				_, err := LoadMap(map[string]any{"Name": "map", "db": map[string]any{"port": 1}}, v)
+				return err
+			},
+			want: sourceConfig{Name: "map", Port: 80, DB: sourceDB{Port: 1}},
+		},
+	}
+	useProfile(t, "")
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var cfg sourceConfig
+			if err := c.load(&cfg); err != nil {
+				t.Fatal(err)
+			}
+			if cfg != c.want {
+				t.Errorf("got %+v, want %+v", cfg, c.want)
+			}
+		})
+	}
+}
+
+func TestLoadSourceErrors(t *testing.T) {
+	var cfg sourceConfig
+	cases := []struct {
+		name string
+		err  func() error
+		msg  string
+	}{
+		{"format required", func() error {
+			_, err := LoadBytes([]byte("name: a"), "", &cfg)
+			return err
+		}, "format is required"},
+		{"include rejected", func() error {
+			_, err := LoadReader(strings.NewReader("include: base.yaml"), "yaml", &cfg)
+			return err
+		}, "include is only supported when loading from files"},
+		{"syntax error line", func() error {
+			_, err := LoadBytes([]byte("{\n\"name\": \"a\",\n}"), "json", &cfg)
+			return err
+		}, "line 3"},
+		{"fs missing", func() error {
+			_, err := LoadFS(fstest.MapFS{}, "config.yaml", &cfg)
+			return err
+		}, "config.yaml"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := c.err(); err == nil || !strings.Contains(err.Error(), c.msg) {
+				t.Errorf("error = %v, want one containing %q", err, c.msg)
+			}
+		})
+	}
+}
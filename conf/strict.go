@@ -0,0 +1,154 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// knownKeys 配置结构体中的所有 key，open 为 map、interface 等可以包含任意子 key 的字段
+type knownKeys struct {
+	keys   map[string]bool
+	leaves []string
+	open   []string
+}
+
+func newKnownKeys(v any) *knownKeys {
+	k := &knownKeys{keys: map[string]bool{}}
+	walkFields(reflect.TypeOf(v), func(f field) bool {
+		k.keys[f.Key] = true
+		if !f.Nested {
+			k.leaves = append(k.leaves, f.Key)
+		}
+		t := f.Field.Type
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() == reflect.Map || t.Kind() == reflect.Interface {
+			k.open = append(k.open, f.Key)
+		}
+		return true
+	})
+	return k
+}
+
+// has 判断 key 是否对应结构体中的字段
+func (k *knownKeys) has(key string) bool {
+	if k.keys[key] {
+		return true
+	}
+	return hasPrefixKey(key, k.open)
+}
+
+// suggest 返回与 key 最接近的已知 key，差距过大时返回空字符串
+func (k *knownKeys) suggest(key string) string {
+	best, bestDist := "", -1
+	for _, candidate := range k.leaves {
+		d := levenshtein(key, candidate)
+		if bestDist == -1 || d < bestDist {
+			best, bestDist = candidate, d
+		}
+	}
+	limit := len(key) / 3
+	if limit < 2 {
+		limit = 2
+	}
+	if bestDist < 0 || bestDist > limit {
+		return ""
+	}
+	return best
+}
+
+// hasPrefixKey 判断 key 是否等于 prefixes 中的某一项或是其子 key
+func hasPrefixKey(key string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if key == p || strings.HasPrefix(key, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkStrict 检查配置中无法对应到结构体字段的 key，ignore 中的 key（如已迁移的废弃 key）会被跳过
+func checkStrict(cfg *viper.Viper, v any, ignore []string) error {
+	known := newKnownKeys(v)
+	var unknown []string
+	for _, key := range cfg.AllKeys() {
+		if known.has(key) || hasPrefixKey(key, ignore) {
+			continue
+		}
+		unknown = append(unknown, key)
+	}
+	sort.Strings(unknown)
+	errs := make([]error, 0, len(unknown))
+	for _, key := range unknown {
+		msg := fmt.Sprintf("conf: unknown key %q", key)
+		if file := cfg.ConfigFileUsed(); file != "" {
+			msg += " in " + file
+		}
+		if s := known.suggest(key); s != "" {
+			msg += fmt.Sprintf(", did you mean %q?", s)
+		}
+		errs = append(errs, errors.New(msg))
+	}
+	return errors.Join(errs...)
+}
+
+// migrateDeprecated 将 deprecated:"old.key" 标记的旧 key 迁移到新 key，返回已迁移的旧 key。
+// 旧 key 的值作为新 key 的默认值，新 key 在配置文件、环境变量或命令行中出现时仍以新 key 为准
+func migrateDeprecated(cfg *viper.Viper, v any) []string {
+	var migrated []string
+	walkFields(reflect.TypeOf(v), func(f field) bool {
+		tag := f.Field.Tag.Get("deprecated")
+		if tag == "" {
+			return true
+		}
+		set := cfg.IsSet(f.Key)
+		defaulted := false
+		for _, old := range strings.Split(tag, ",") {
+			old = strings.ToLower(strings.TrimSpace(old))
+			if old == "" || !cfg.IsSet(old) {
+				continue
+			}
+			migrated = append(migrated, old)
+			if set || defaulted {
+				logf("conf: deprecated key %q ignored, %q is already set", old, f.Key)
+			} else {
+				logf("conf: key %q is deprecated, use %q instead", old, f.Key)
+			}
+			// 以默认值的优先级设置，配置文件、环境变量和命令行参数中的新 key 仍然优先；
+			// 同时使新 key 出现在 AllKeys 中，只在环境变量中设置的新 key 才能被 Unmarshal 读取
+			if !defaulted {
+				cfg.SetDefault(f.Key, cfg.Get(old))
+				defaulted = true
+			}
+		}
+		return true
+	})
+	return migrated
+}
+
+// levenshtein 计算两个字符串的编辑距离
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
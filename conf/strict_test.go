@@ -0,0 +1,110 @@
+package conf
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type strictConfig struct {
+	Name    string
+	Timeout int               `mapstructure:"request_timeout" deprecated:"timeout,http.timeout"`
+	Labels  map[string]string // map 可以包含任意子 key
+	Server  struct {
+		Port int
+	}
+}
+
+func TestStrict(t *testing.T) {
+	cases := []struct {
+		name string
+		m    map[string]any
+		errs []string
+	}{
+		{"known keys", map[string]any{"name": "a", "labels": map[string]any{"any": "x"}, "server": map[string]any{"port": 80}}, nil},
+		{"deprecated key", map[string]any{"timeout": 5}, nil},
+		{"unknown with suggestion", map[string]any{"nmae": "a", "server": map[string]any{"prot": 80}}, []string{
+			`unknown key "nmae", did you mean "name"?`,
+			`unknown key "server.prot", did you mean "server.port"?`,
+		}},
+		{"unknown without suggestion", map[string]any{"completely_different": 1}, []string{
+			`unknown key "completely_different"`,
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var cfg strictConfig
+			_, err := LoadMap(c.m, &cfg, WithStrict())
+			if len(c.errs) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, msg := range c.errs {
+				if !strings.Contains(err.Error(), msg) {
+					t.Errorf("error %q does not contain %q", err, msg)
+				}
+			}
+			if strings.Contains(err.Error(), "did you mean") != strings.Contains(strings.Join(c.errs, ""), "did you mean") {
+				t.Errorf("unexpected suggestion in %q", err)
+			}
+		})
+	}
+	var cfg strictConfig
+	if _, err := LoadMap(map[string]any{"nmae": "a"}, &cfg); err != nil {
+		t.Errorf("unknown keys must be ignored without WithStrict: %v", err)
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	var logs []string
+	SetLogger(func(format string, args ...any) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	})
+	t.Cleanup(func() { SetLogger(nil) })
+
+	cases := []struct {
+		name string
+		m    map[string]any
+		env  string
+		want int
+		log  string
+	}{
+		{"old key", map[string]any{"timeout": 5}, "", 5, `key "timeout" is deprecated, use "request_timeout" instead`},
+		{"nested old key", map[string]any{"http": map[string]any{"timeout": 6}}, "", 6, `key "http.timeout" is deprecated`},
+		{"new key wins", map[string]any{"timeout": 5, "request_timeout": 7}, "", 7, `deprecated key "timeout" ignored`},
+		{"env wins over old key", map[string]any{"timeout": 5}, "8", 8, `deprecated key "timeout" ignored`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			logs = nil
+			if c.env != "" {
+				t.Setenv("REQUEST_TIMEOUT", c.env)
+			}
+			var cfg strictConfig
+			if _, err := LoadMap(c.m, &cfg); err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Timeout != c.want {
+				t.Errorf("timeout = %d, want %d", cfg.Timeout, c.want)
+			}
+			if len(logs) != 1 || !strings.Contains(logs[0], c.log) {
+				t.Errorf("logs = %q, want %q", logs, c.log)
+			}
+		})
+	}
+
+	t.Run("flag wins over old key", func(t *testing.T) {
+		var cfg strictConfig
+		if _, err := LoadMap(map[string]any{"timeout": 5}, &cfg, WithFlags([]string{"--request_timeout=9"})); err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Timeout != 9 {
+			t.Errorf("timeout = %d, want 9", cfg.Timeout)
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package conf
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// ByteSize 字节大小，可写作 "256MiB"、"100MB"、"512K" 或字节数，KB/MB 为 1000 进制，K/KiB/MiB 为 1024 进制
+type ByteSize uint64
+
+const (
+	Byte ByteSize = 1
+	KiB           = 1024 * Byte
+	MiB           = 1024 * KiB
+	GiB           = 1024 * MiB
+	TiB           = 1024 * GiB
+	PiB           = 1024 * TiB
+)
+
+// Bytes 返回字节数
+func (b ByteSize) Bytes() uint64 {
+	return uint64(b)
+}
+
+// String 返回能整除的最大单位，如 256MiB、1500KB，否则为字节数
+func (b ByteSize) String() string {
+	if b == 0 {
+		return "0B"
+	}
+	for _, unit := range []struct {
+		suffix string
+		size   ByteSize
+	}{
+		{"PiB", PiB}, {"PB", 1e15}, {"TiB", TiB}, {"TB", 1e12}, {"GiB", GiB},
+		{"GB", 1e9}, {"MiB", MiB}, {"MB", 1e6}, {"KiB", KiB}, {"KB", 1e3},
+	} {
+		if b%unit.size == 0 {
+			return strconv.FormatUint(uint64(b/unit.size), 10) + unit.suffix
+		}
+	}
+	return strconv.FormatUint(uint64(b), 10) + "B"
+}
+
+func (b ByteSize) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+		*b = ByteSize(n)
+		return nil
+	}
+	n, err := parseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*b = ByteSize(n)
+	return nil
+}
+
+// Duration 时间间隔，必须带单位，如 "300ms"、"5m"、"1h30m"，避免裸数字的单位歧义
+type Duration time.Duration
+
+// Std 返回 time.Duration
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	val, err := time.ParseDuration(strings.TrimSpace(string(text)))
+	if err != nil {
+		return err
+	}
+	*d = Duration(val)
+	return nil
+}
+
+// Percent 百分比，以小数保存（75% 为 0.75），可写作 "75%" 或小数 0.75
+type Percent float64
+
+// Float 返回小数形式
+func (p Percent) Float() float64 {
+	return float64(p)
+}
+
+// Of 返回 n 的百分比
+func (p Percent) Of(n float64) float64 {
+	return n * float64(p)
+}
+
+func (p Percent) String() string {
+	return strconv.FormatFloat(float64(p)*100, 'f', -1, 64) + "%"
+}
+
+func (p Percent) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Percent) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	if num, ok := strings.CutSuffix(s, "%"); ok {
+		val, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+		if err != nil {
+			return fmt.Errorf("invalid percent %q", s)
+		}
+		*p = Percent(val / 100)
+		return nil
+	}
+	val, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("invalid percent %q", s)
+	}
+	*p = Percent(val)
+	return nil
+}
+
+var (
+	byteSizeType = reflect.TypeOf(ByteSize(0))
+	confDurType  = reflect.TypeOf(Duration(0))
+	percentType  = reflect.TypeOf(Percent(0))
+)
+
+// decodeHook Unmarshal 使用的类型转换，在 viper 默认转换的基础上支持 encoding.TextUnmarshaler，
+// ByteSize、Percent 也接受数字形式的值；Duration 必须带单位，数字会返回缺少单位的错误
+func decodeHook() mapstructure.DecodeHookFunc {
+	return mapstructure.ComposeDecodeHookFunc(
+		textUnmarshalerHook,
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	)
+}
+
+func textUnmarshalerHook(from, to reflect.Type, data any) (any, error) {
+	if !reflect.PointerTo(to).Implements(textUnmarshalerType) || from == to {
+		return data, nil
+	}
+	var text string
+	switch {
+	case from.Kind() == reflect.String:
+		text = reflect.ValueOf(data).String()
+	case to == byteSizeType || to == confDurType || to == percentType:
+		switch from.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			text = fmt.Sprint(data)
+		case reflect.Float32, reflect.Float64:
+			// 避免 1.5e9 被格式化为指数形式
+			text = strconv.FormatFloat(reflect.ValueOf(data).Float(), 'f', -1, from.Bits())
+		default:
+			return data, nil
+		}
+	default:
+		return data, nil
+	}
+	v := reflect.New(to)
+	if err := v.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text)); err != nil {
+		return nil, err
+	}
+	return v.Elem().Interface(), nil
+}
@@ -0,0 +1,119 @@
+package conf
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestByteSize(t *testing.T) {
+	cases := []struct {
+		in   string
+		want ByteSize
+		ok   bool
+	}{
+		{"1024", 1024, true},
+		{"0", 0, true},
+		{"256MiB", 256 * MiB, true},
+		{"256mib", 256 * MiB, true},
+		{"100MB", 100e6, true},
+		{"512K", 512 * KiB, true},
+		{"1.5GiB", 1536 * MiB, true},
+		{" 2 KB ", 2000, true},
+		{"10B", 10, true},
+		{"1PiB", PiB, true},
+		{"-1MiB", 0, false},
+		{"MiB", 0, false},
+		{"1XB", 0, false},
+		{"100000PiB", 0, false},
+	}
+	for _, c := range cases {
+		var b ByteSize
+		err := b.UnmarshalText([]byte(c.in))
+		if (err == nil) != c.ok || b != c.want {
+			t.Errorf("%q = %d, %v, want %d ok %v", c.in, b, err, c.want, c.ok)
+		}
+	}
+
+	for _, c := range []struct {
+		b    ByteSize
+		want string
+	}{
+		{0, "0B"}, {1, "1B"}, {1023, "1023B"}, {KiB, "1KiB"}, {256 * MiB, "256MiB"},
+		{1500e3, "1500KB"}, {3 * GiB, "3GiB"}, {2e9, "2GB"},
+	} {
+		if got := c.b.String(); got != c.want {
+			t.Errorf("ByteSize(%d) = %q, want %q", c.b, got, c.want)
+		}
+		var back ByteSize
+		if err := back.UnmarshalText([]byte(c.want)); err != nil || back != c.b {
+			t.Errorf("round trip %q = %d, %v", c.want, back, err)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	var d Duration
+	if err := d.UnmarshalText([]byte(" 1h30m ")); err != nil || d.Std() != 90*time.Minute {
+		t.Errorf("1h30m = %s, %v", d, err)
+	}
+	for _, in := range []string{"5", "", "soon"} {
+		if err := d.UnmarshalText([]byte(in)); err == nil {
+			t.Errorf("%q should fail", in)
+		}
+	}
+	if text, _ := Duration(300 * time.Millisecond).MarshalText(); string(text) != "300ms" {
+		t.Errorf("marshal = %s", text)
+	}
+}
+
+func TestPercent(t *testing.T) {
+	cases := []struct {
+		in   string
+		want Percent
+		ok   bool
+	}{
+		{"75%", 0.75, true},
+		{" 12.5 % ", 0.125, true},
+		{"0.75", 0.75, true},
+		{"150%", 1.5, true},
+		{"%", 0, false},
+		{"half", 0, false},
+	}
+	for _, c := range cases {
+		var p Percent
+		err := p.UnmarshalText([]byte(c.in))
+		if (err == nil) != c.ok || p != c.want {
+			t.Errorf("%q = %v, %v, want %v ok %v", c.in, float64(p), err, float64(c.want), c.ok)
+		}
+	}
+	if p := Percent(0.75); p.String() != "75%" || p.Of(200) != 150 || p.Float() != 0.75 {
+		t.Errorf("Percent(0.75) = %s %v %v", p, p.Of(200), p.Float())
+	}
+}
+
+func TestDecodeTypes(t *testing.T) {
+	type config struct {
+		Size     ByteSize
+		Count    ByteSize
+		Big      ByteSize
+		Interval Duration
+		Usage    Percent
+		Ratio    Percent
+	}
+	var cfg config
+	_, err := LoadBytes([]byte("size: 256MiB\ncount: 1024\nbig: 1500000000.0\ninterval: 5s\nusage: 75%\nratio: 0.5\n"), "yaml", &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := config{Size: 256 * MiB, Count: 1024, Big: 1500e6, Interval: Duration(5 * time.Second), Usage: 0.75, Ratio: 0.5}
+	if cfg != want {
+		t.Errorf("got %+v, want %+v", cfg, want)
+	}
+
+	// Duration 不接受没有单位的数字
+	_, err = LoadBytes([]byte("interval: 5\n"), "yaml", &cfg)
+	if err == nil || !strings.Contains(err.Error(), "missing unit") {
+		t.Errorf("bare number duration error = %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package conf
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rules validate 标签解析结果，例如 validate:"required,min=1,max=100,oneof=debug info warn"。
+// 标签与 go-playground/validator 兼容：其他规则会被忽略，dive 之后的规则作用于元素，同样忽略
+type rules struct {
+	required bool
+	min, max string
+	oneof    []string
+}
+
+func parseRules(tag string) (rules, error) {
+	var r rules
+	if tag == "" || tag == "-" {
+		return r, nil
+	}
+	for _, item := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(item), "=")
+		switch name {
+		case "required":
+			r.required = true
+		case "min":
+			r.min = arg
+		case "max":
+			r.max = arg
+		case "oneof":
+			r.oneof = strings.Fields(arg)
+		case "dive":
+			return r, nil
+		}
+	}
+	return r, nil
+}
+
+// Validate 按 validate 标签校验配置结构体，返回所有不满足规则的字段
+func Validate(v any) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs []error
+	validateStruct(rv, nil, &errs)
+	return errors.Join(errs...)
+}
+
+func validateStruct(v reflect.Value, path []string, errs *[]error) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		fieldPath := append(append([]string{}, path...), sf.Name)
+		r, err := parseRules(sf.Tag.Get("validate"))
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("conf: %s: %w", strings.Join(fieldPath, "."), err))
+			continue
+		}
+		if err = r.check(fv); err != nil {
+			*errs = append(*errs, fmt.Errorf("conf: %s: %w", strings.Join(fieldPath, "."), err))
+			continue
+		}
+		if !isNested(sf.Type) {
+			continue
+		}
+		for fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				break
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct {
+			validateStruct(fv, fieldPath, errs)
+		}
+	}
+}
+
+// check 校验单个字段的值
+func (r rules) check(v reflect.Value) error {
+	if v.IsZero() {
+		if r.required {
+			return errors.New("is required")
+		}
+		return nil
+	}
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if len(r.oneof) > 0 {
+		s := fmt.Sprint(v.Interface())
+		found := false
+		for _, item := range r.oneof {
+			if item == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("must be one of [%s], got %q", strings.Join(r.oneof, " "), s)
+		}
+	}
+	if r.min != "" {
+		n, limit, err := measure(v, r.min)
+		if err != nil {
+			return err
+		}
+		if n < limit {
+			return fmt.Errorf("must be at least %s", r.min)
+		}
+	}
+	if r.max != "" {
+		n, limit, err := measure(v, r.max)
+		if err != nil {
+			return err
+		}
+		if n > limit {
+			return fmt.Errorf("must be at most %s", r.max)
+		}
+	}
+	return nil
+}
+
+// measure 返回用于 min/max 比较的值：数字比较大小，字符串、切片和 map 比较长度
+func measure(v reflect.Value, arg string) (float64, float64, error) {
+	if v.Type() == byteSizeType || v.Type() == percentType {
+		limit := reflect.New(v.Type())
+		if err := limit.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(arg)); err != nil {
+			return 0, 0, fmt.Errorf("invalid limit %q", arg)
+		}
+		return toFloat64(v), toFloat64(limit.Elem()), nil
+	}
+	if v.Type() == durationType || v.Type() == confDurType {
+		limit, err := time.ParseDuration(arg)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid duration limit %q", arg)
+		}
+		return float64(v.Int()), float64(limit), nil
+	}
+	limit, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid limit %q", arg)
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), limit, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), limit, nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), limit, nil
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), limit, nil
+	}
+	return 0, 0, fmt.Errorf("min/max not supported on %s", v.Type())
+}
+
+func toFloat64(v reflect.Value) float64 {
+	if v.CanUint() {
+		return float64(v.Uint())
+	}
+	return v.Float()
+}
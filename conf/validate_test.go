@@ -0,0 +1,73 @@
+package conf
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type validateConfig struct {
+	Name    string        `validate:"required"`
+	Level   string        `validate:"oneof=debug info warn"`
+	Workers int           `validate:"min=1,max=8"`
+	Timeout time.Duration `validate:"min=1s"`
+	Size    ByteSize      `validate:"max=1MiB"`
+	Hosts   []string      `validate:"required,min=1,dive,hostname"`
+	Email   string        `validate:"omitempty,email"`
+	DB      struct {
+		DSN string `validate:"required"`
+	}
+}
+
+func TestLoadValidate(t *testing.T) {
+	invalid := map[string]any{"level": "trace", "workers": 9, "timeout": "10ms", "size": "2MiB"}
+	var cfg validateConfig
+	if _, err := LoadMap(invalid, &cfg); err != nil {
+		t.Fatalf("validate tags must not be checked without WithValidate: %v", err)
+	}
+	_, err := LoadMap(invalid, &cfg, WithValidate())
+	if err == nil {
+		t.Fatal("WithValidate should fail")
+	}
+	for _, field := range []string{"Name", "Level", "Workers", "Timeout", "Size", "Hosts", "DB.DSN"} {
+		if !strings.Contains(err.Error(), "conf: "+field+":") {
+			t.Errorf("error does not mention %s:\n%v", field, err)
+		}
+	}
+	if strings.Contains(err.Error(), "Email") {
+		t.Errorf("unknown rules should be ignored:\n%v", err)
+	}
+
+	valid := map[string]any{
+		"name": "app", "level": "info", "workers": 8, "timeout": "1s", "size": "1MiB",
+		"hosts": []any{"a"}, "email": "not checked", "db": map[string]any{"dsn": "x"},
+	}
+	if _, err = LoadMap(valid, &cfg, WithValidate()); err != nil {
+		t.Errorf("valid config: %v", err)
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	cases := []struct {
+		tag  string
+		want rules
+	}{
+		{"", rules{}},
+		{"-", rules{}},
+		{"required,min=1,max=10", rules{required: true, min: "1", max: "10"}},
+		{"oneof=a b  c", rules{oneof: []string{"a", "b", "c"}}},
+		{"omitempty,email,len=3", rules{}},
+		{"min=1,dive,required,max=5", rules{min: "1"}},
+	}
+	for _, c := range cases {
+		got, err := parseRules(c.tag)
+		if err != nil {
+			t.Errorf("%q: %v", c.tag, err)
+			continue
+		}
+		if got.required != c.want.required || got.min != c.want.min || got.max != c.want.max ||
+			strings.Join(got.oneof, " ") != strings.Join(c.want.oneof, " ") {
+			t.Errorf("%q = %+v, want %+v", c.tag, got, c.want)
+		}
+	}
+}
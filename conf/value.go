@@ -0,0 +1,138 @@
+package conf
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Value 单个配置项的动态值，读取无锁，配置重新加载后自动更新。
+// 组件只需要持有自己关心的值，例如：
+//
+//	level, _ := conf.Bind[string](w, "log.loglevel")
+//	level.Apply(logger.SetLevel)
+type Value[T any] struct {
+	key      string
+	rules    rules
+	cur      atomic.Pointer[T]
+	mu       sync.Mutex
+	handlers []func(v T)
+}
+
+// NewValue 创建不绑定配置的值，可以通过 Set 手动修改
+func NewValue[T any](v T) *Value[T] {
+	val := &Value[T]{}
+	val.cur.Store(&v)
+	return val
+}
+
+// Bind 将 key 对应的配置字段绑定为动态值，T 必须与字段类型一致。
+// 初始值已经过默认值处理（Watch 使用 WithValidate 时也经过校验），Watcher 重新加载成功后更新并通知订阅者
+func Bind[T any](w *Watcher, key string) (*Value[T], error) {
+	key = strings.ToLower(key)
+	f, ok := lookupField(w.typ, key)
+	if !ok {
+		return nil, fmt.Errorf("conf: unknown key %q", key)
+	}
+	if want := reflect.TypeOf((*T)(nil)).Elem(); f.Field.Type != want {
+		return nil, fmt.Errorf("conf: key %q is %s, not %s", key, f.Field.Type, want)
+	}
+	r, err := parseRules(f.Field.Tag.Get("validate"))
+	if err != nil {
+		return nil, fmt.Errorf("conf: %s: %w", key, err)
+	}
+	val := &Value[T]{key: key, rules: r}
+	read := func(v any) (T, bool) {
+		fv, ok := fieldValue(reflect.ValueOf(v), f.Path)
+		if !ok {
+			var zero T
+			return zero, false
+		}
+		return fv.Interface().(T), true
+	}
+	initial, _ := read(w.Value())
+	val.cur.Store(&initial)
+	w.OnChange(func(v any) {
+		if next, ok := read(v); ok {
+			val.update(next)
+		}
+	})
+	return val, nil
+}
+
+// Key 返回绑定的 key，NewValue 创建的值为空
+func (v *Value[T]) Key() string {
+	return v.key
+}
+
+// Load 返回当前值
+func (v *Value[T]) Load() T {
+	return *v.cur.Load()
+}
+
+// Set 修改当前值，值不满足字段的 validate 规则时返回错误，下一次重新加载会覆盖该值
+func (v *Value[T]) Set(val T) error {
+	if err := v.rules.check(reflect.ValueOf(&val).Elem()); err != nil {
+		return fmt.Errorf("conf: %s: %w", v.key, err)
+	}
+	v.update(val)
+	return nil
+}
+
+// OnChange 注册值变化的回调，只有值实际变化时才会调用
+func (v *Value[T]) OnChange(fn func(v T)) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.handlers = append(v.handlers, fn)
+}
+
+// Apply 立即以当前值调用 fn，并在之后每次变化时调用
+func (v *Value[T]) Apply(fn func(v T)) {
+	fn(v.Load())
+	v.OnChange(fn)
+}
+
+func (v *Value[T]) update(val T) {
+	old := v.cur.Swap(&val)
+	if reflect.DeepEqual(*old, val) {
+		return
+	}
+	v.mu.Lock()
+	handlers := append([]func(v T){}, v.handlers...)
+	v.mu.Unlock()
+	for _, fn := range handlers {
+		fn(val)
+	}
+}
+
+// lookupField 查找 key 对应的字段
+func lookupField(t reflect.Type, key string) (field, bool) {
+	var found field
+	var ok bool
+	walkFields(t, func(f field) bool {
+		if f.Key == key {
+			found, ok = f, true
+		}
+		return !ok
+	})
+	return found, ok
+}
+
+// fieldValue 按字段路径取值，路径上遇到 nil 指针时返回 false
+func fieldValue(v reflect.Value, path []string) (reflect.Value, bool) {
+	for _, name := range path {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.FieldByName(name)
+		if !v.IsValid() {
+			return reflect.Value{}, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,306 @@
+package conf
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// Source 可以监听变化的配置来源
+type Source interface {
+	// Read 将配置读入 viper
+	Read(cfg *viper.Viper) error
+	// Watch 阻塞监听配置变化，每次变化调用 notify，ctx 结束时返回
+	Watch(ctx context.Context, notify func()) error
+}
+
+// File 本地配置文件来源，环境变量 CONF_PATH 优先于 path
+func File(path string) Source {
+	return &fileWatcher{path: path}
+}
+
+type fileWatcher struct {
+	path string
+}
+
+func (f *fileWatcher) Read(cfg *viper.Viper) error {
+	return fileSource(configPath(f.path))(cfg, Profile())
+}
+
+func (f *fileWatcher) readProfile(cfg *viper.Viper, profile string) error {
+	return fileSource(configPath(f.path))(cfg, profile)
+}
+
+// profileSource 支持按 profile 读取覆盖文件的配置来源
+type profileSource interface {
+	readProfile(cfg *viper.Viper, profile string) error
+}
+
+// sourceOf 将 Source 转为加载流程使用的 source
+func sourceOf(src Source) source {
+	return func(cfg *viper.Viper, profile string) error {
+		if ps, ok := src.(profileSource); ok {
+			return ps.readProfile(cfg, profile)
+		}
+		return src.Read(cfg)
+	}
+}
+
+// Watch 监听配置文件、profile 覆盖文件以及通过 include 引入的文件，
+// 兼容编辑器的原子替换以及 k8s ConfigMap 的软链接切换
+func (f *fileWatcher) Watch(ctx context.Context, notify func()) error {
+	file := configPath(f.path)
+	return watchFiles(ctx, func() []string {
+		return osFiles.trackFiles(func(tf *files) error {
+			_, err := tf.readWithOverlay(file, Profile())
+			return err
+		})
+	}, notify, filepath.Dir(file))
+}
+
+// trackFiles 返回 read 过程中读取过（包括尝试读取但不存在）的所有文件
+func (f *files) trackFiles(read func(tf *files) error) []string {
+	var names []string
+	tracked := *f
+	tracked.readFile = func(name string) ([]byte, error) {
+		names = append(names, name)
+		return f.readFile(name)
+	}
+	_ = read(&tracked)
+	return names
+}
+
+// watchFiles 监听 resolve 返回的文件以及 dirs，文件被修改、创建、删除或软链接指向变化时调用 notify。
+// 每次事件后重新 resolve，新引入的文件（如通配符新匹配到的文件）也会被监听
+func watchFiles(ctx context.Context, resolve func() []string, notify func(), dirs ...string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	watched := map[string]bool{}
+	add := func(dir string) error {
+		if watched[dir] {
+			return nil
+		}
+		if err := watcher.Add(dir); err != nil {
+			return err
+		}
+		watched[dir] = true
+		return nil
+	}
+	// track 返回文件的绝对路径到软链接目标的映射，并监听新出现的目录
+	track := func() (map[string]string, error) {
+		files := map[string]string{}
+		for _, name := range resolve() {
+			abs, err := filepath.Abs(name)
+			if err != nil {
+				continue
+			}
+			files[abs], _ = filepath.EvalSymlinks(abs)
+			if err = add(filepath.Dir(abs)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return files, err
+			}
+		}
+		return files, nil
+	}
+	for _, dir := range dirs {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		if err = add(dir); err != nil {
+			return err
+		}
+	}
+	files, err := track()
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			name := filepath.Clean(event.Name)
+			next, err := track()
+			if err != nil {
+				logf("conf: watch %s: %v", name, err)
+			}
+			_, before := files[name]
+			_, after := next[name]
+			if before || after || relinked(files, next) {
+				notify()
+			}
+			files = next
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logf("conf: watch: %v", err)
+		}
+	}
+}
+
+// relinked 判断是否有文件的软链接目标发生了变化
+func relinked(before, after map[string]string) bool {
+	for name, target := range after {
+		if old, ok := before[name]; ok && old != target {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadSource 从任意配置来源加载配置
+func LoadSource(src Source, v any, opts ...Option) (*viper.Viper, error) {
+	return load(sourceOf(src), v, opts...)
+}
+
+//================================================================================
+
+// Watcher 监听配置来源，变化时重新执行完整的加载流程并通知订阅者。
+// 重新加载失败时保留上一次成功的配置
+type Watcher struct {
+	src      Source
+	typ      reflect.Type
+	opts     []Option
+	cfg      atomic.Pointer[viper.Viper]
+	value    atomic.Value
+	mu       sync.Mutex
+	handlers []func(v any)
+	reloadMu sync.Mutex // 串行执行 Reload，保证订阅者按加载顺序收到配置
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// Watch 加载配置到 v 并开始监听变化，v 必须是结构体指针
+func Watch(src Source, v any, opts ...Option) (*Watcher, error) {
+	if reflect.TypeOf(v) == nil || reflect.TypeOf(v).Kind() != reflect.Ptr {
+		return nil, errors.New("not a pointer")
+	}
+	var sources map[string]string
+	cfg, err := load(sourceOf(src), v, append(append([]Option{}, opts...), WithProvenance(&sources))...)
+	if err != nil {
+		return nil, err
+	}
+	provenances.Store(v, sources)
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &Watcher{
+		src:    src,
+		typ:    reflect.TypeOf(v).Elem(),
+		opts:   opts,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	w.cfg.Store(cfg)
+	w.value.Store(v)
+
+	changes := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	}
+	go func() {
+		if err := src.Watch(ctx, notify); err != nil && ctx.Err() == nil {
+			logf("conf: watch stopped: %v", err)
+		}
+	}()
+	//配置片段目录中的文件以及它们引入的文件
+	if dir := newOptions(opts).confDir; dir != "" {
+		go func() {
+			err := watchFiles(ctx, func() []string {
+				return osFiles.trackFiles(func(tf *files) error {
+					_, err := tf.readFragments(dir)
+					return err
+				})
+			}, notify, dir)
+			if err != nil && ctx.Err() == nil {
+				logf("conf: watch %s stopped: %v", dir, err)
+			}
+		}()
+	}
+	go w.loop(ctx, changes)
+	return w, nil
+}
+
+// loop 合并短时间内的多次变化后再重新加载
+func (w *Watcher) loop(ctx context.Context, changes <-chan struct{}) {
+	defer close(w.done)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changes:
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+		if err := w.Reload(); err != nil {
+			logf("conf: reload failed, keep last config: %v", err)
+		}
+	}
+}
+
+// Reload 立即重新加载配置，成功后通知所有订阅者。
+// 并发调用时依次执行，订阅者不会先收到较新的配置再收到较旧的；不要在 OnChange 回调中调用 Reload
+func (w *Watcher) Reload() error {
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+	v := reflect.New(w.typ).Interface()
+	var sources map[string]string
+	cfg, err := load(sourceOf(w.src), v, append(append([]Option{}, w.opts...), WithProvenance(&sources))...)
+	if err != nil {
+		return err
+	}
+	w.cfg.Store(cfg)
+	provenances.Store(v, sources)
+	forgetProvenance(w.value.Swap(v))
+
+	w.mu.Lock()
+	handlers := append([]func(v any){}, w.handlers...)
+	w.mu.Unlock()
+	for _, fn := range handlers {
+		fn(v)
+	}
+	return nil
+}
+
+// OnChange 注册配置变化的回调，参数为新的配置结构体指针
+func (w *Watcher) OnChange(fn func(v any)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers = append(w.handlers, fn)
+}
+
+// Value 返回当前的配置结构体指针，调用方不应修改其内容
+func (w *Watcher) Value() any {
+	return w.value.Load()
+}
+
+// Config 返回当前配置对应的 viper
+func (w *Watcher) Config() *viper.Viper {
+	return w.cfg.Load()
+}
+
+// Close 停止监听
+func (w *Watcher) Close() error {
+	w.cancel()
+	<-w.done
+	forgetProvenance(w.value.Load())
+	return nil
+}
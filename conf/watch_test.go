@@ -0,0 +1,238 @@
+package conf
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type watchConfig struct {
+	Name    string
+	Port    int    `validate:"max=9000"`
+	Level   string `default:"info"`
+	Workers *int
+}
+
+// waitChange 等待 OnChange 收到满足 ok 的配置
+func waitChange(t *testing.T, name string, changes <-chan *watchConfig, ok func(c *watchConfig) bool) *watchConfig {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case c := <-changes:
+			if ok(c) {
+				return c
+			}
+		case <-timeout:
+			t.Fatalf("%s: config not reloaded", name)
+			return nil
+		}
+	}
+}
+
+func writeFile(t *testing.T, name, data string) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatchFiles(t *testing.T) {
+	useProfile(t, "")
+	dir := writeFiles(t, map[string]string{
+		"config.yaml":         "include: shared/*.yaml\nname: v1\n",
+		"shared/port.yaml":    "port: 80\n",
+		"conf.d/10-base.yaml": "level: debug\n",
+	})
+	var cfg watchConfig
+	w, err := Watch(File(filepath.Join(dir, "config.yaml")), &cfg, WithoutEnvPath(), WithConfDir(filepath.Join(dir, "conf.d")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if cfg.Name != "v1" || cfg.Port != 80 || cfg.Level != "debug" {
+		t.Fatalf("initial config %+v", cfg)
+	}
+	changes := make(chan *watchConfig, 10)
+	w.OnChange(func(v any) { changes <- v.(*watchConfig) })
+	time.Sleep(100 * time.Millisecond) // 等待开始监听
+
+	cases := []struct {
+		name  string
+		write func()
+		ok    func(c *watchConfig) bool
+	}{
+		{"main file", func() {
+			writeFile(t, filepath.Join(dir, "config.yaml"), "include: shared/*.yaml\nname: v2\n")
+		}, func(c *watchConfig) bool { return c.Name == "v2" }},
+		{"included file", func() {
+			writeFile(t, filepath.Join(dir, "shared", "port.yaml"), "port: 81\n")
+		}, func(c *watchConfig) bool { return c.Port == 81 }},
+		{"new glob match", func() {
+			writeFile(t, filepath.Join(dir, "shared", "z.yaml"), "port: 82\n")
+		}, func(c *watchConfig) bool { return c.Port == 82 }},
+		{"new fragment", func() {
+			writeFile(t, filepath.Join(dir, "conf.d", "20-level.yaml"), "level: warn\n")
+		}, func(c *watchConfig) bool { return c.Level == "warn" }},
+		{"overlay file", func() {
+			writeFile(t, filepath.Join(dir, "config.dev.yaml"), "name: dev\n")
+		}, func(c *watchConfig) bool { return c.Name == "dev" }},
+	}
+	for _, c := range cases {
+		c.write()
+		got := waitChange(t, c.name, changes, c.ok)
+		if w.Value().(*watchConfig) != got {
+			t.Errorf("%s: Value() is not the reloaded config", c.name)
+		}
+	}
+
+	// 重新加载失败时保留上一次成功的配置
+	failed := make(chan string, 10)
+	SetLogger(func(format string, args ...any) { failed <- fmt.Sprintf(format, args...) })
+	t.Cleanup(func() { SetLogger(nil) })
+	writeFile(t, filepath.Join(dir, "shared", "port.yaml"), "port: [")
+	select {
+	case msg := <-failed:
+		if !strings.Contains(msg, "reload failed, keep last config") {
+			t.Errorf("log = %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reload failure not logged")
+	}
+	if got := w.Value().(*watchConfig); got.Port != 82 || got.Name != "dev" {
+		t.Errorf("config after a failed reload = %+v", got)
+	}
+}
+
+// counterSource 每次读取返回递增的 port，slow 时越早的读取越慢
+type counterSource struct {
+	n    atomic.Int64
+	slow bool
+}
+
+func (s *counterSource) Read(cfg *viper.Viper) error {
+	n := s.n.Add(1)
+	if s.slow && n < 30 {
+		time.Sleep(time.Duration(30-n) * time.Millisecond)
+	}
+	cfg.Set("port", n)
+	return nil
+}
+
+func (s *counterSource) Watch(ctx context.Context, notify func()) error {
+	<-ctx.Done()
+	return nil
+}
+
+func TestWatcherReloadOrder(t *testing.T) {
+	var cfg watchConfig
+	w, err := Watch(&counterSource{slow: true}, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	var mu sync.Mutex
+	var ports []int
+	w.OnChange(func(v any) {
+		mu.Lock()
+		ports = append(ports, v.(*watchConfig).Port)
+		mu.Unlock()
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := w.Reload(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(ports) != 20 {
+		t.Fatalf("%d notifications, want 20", len(ports))
+	}
+	for i := 1; i < len(ports); i++ {
+		if ports[i] <= ports[i-1] {
+			t.Fatalf("handlers called out of order: %v", ports)
+		}
+	}
+	if got := w.Value().(*watchConfig).Port; got != ports[len(ports)-1] {
+		t.Errorf("Value() port = %d, want %d", got, ports[len(ports)-1])
+	}
+}
+
+func TestBind(t *testing.T) {
+	var cfg watchConfig
+	src := &counterSource{}
+	w, err := Watch(src, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	for key, msg := range map[string]string{
+		"missing": `unknown key "missing"`,
+		"name":    "is string, not int",
+	} {
+		if _, err = Bind[int](w, key); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("Bind(%q) error = %v, want %q", key, err, msg)
+		}
+	}
+
+	port, err := Bind[int](w, "Port")
+	if err != nil {
+		t.Fatal(err)
+	}
+	level, err := Bind[string](w, "level")
+	if err != nil {
+		t.Fatal(err)
+	}
+	workers, err := Bind[*int](w, "workers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port.Key() != "port" || port.Load() != 1 || level.Load() != "info" || workers.Load() != nil {
+		t.Errorf("initial values %d %q %v", port.Load(), level.Load(), workers.Load())
+	}
+
+	var applied []int
+	port.Apply(func(v int) { applied = append(applied, v) })
+	levelChanged := false
+	level.OnChange(func(string) { levelChanged = true })
+	if err = w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if port.Load() != 2 || len(applied) != 2 || applied[1] != 2 {
+		t.Errorf("port = %d, applied %v", port.Load(), applied)
+	}
+	if levelChanged {
+		t.Error("OnChange called for an unchanged value")
+	}
+
+	// Set 按字段的 validate 规则校验
+	if err = port.Set(9001); err == nil {
+		t.Error("Set should check the validate rules")
+	}
+	if err = port.Set(8080); err != nil || port.Load() != 8080 || applied[len(applied)-1] != 8080 {
+		t.Errorf("Set = %v, port %d", err, port.Load())
+	}
+	// 下一次重新加载覆盖手动设置的值
+	if err = w.Reload(); err != nil || port.Load() != 3 {
+		t.Errorf("after reload port = %d, %v", port.Load(), err)
+	}
+
+	v := NewValue("a")
+	if v.Key() != "" || v.Load() != "a" || v.Set("b") != nil || v.Load() != "b" {
+		t.Errorf("NewValue = %q", v.Load())
+	}
+}
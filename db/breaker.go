@@ -0,0 +1,215 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrCircuitOpen 熔断器打开，请求没有发送到数据库
+var ErrCircuitOpen = errors.New("db: circuit breaker is open")
+
+// 熔断器状态
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// breaker 连续 threshold 次临时错误后打开，打开期间请求直接失败；
+// timeout 后放行一个探测请求，成功则关闭，失败则继续打开
+type breaker struct {
+	name      string
+	threshold int
+	timeout   time.Duration
+	logger    *zap.Logger
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newBreaker(config *Config) *breaker {
+	return &breaker{
+		name:      config.dbName,
+		threshold: config.BreakerThreshold,
+		timeout:   time.Duration(config.BreakerTimeout) * time.Second,
+		logger:    config.Logger.Logger,
+		state:     BreakerClosed,
+	}
+}
+
+// allow 是否放行请求
+func (b *breaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.timeout {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		// 半开状态同时只放行一个探测请求
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// done 记录放行的请求的结果，failed 表示遇到了临时错误
+func (b *breaker) done(failed bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerHalfOpen:
+		b.probing = false
+		if failed {
+			b.open()
+		} else {
+			b.state = BreakerClosed
+			b.failures = 0
+			b.logger.Info("db circuit breaker closed", zap.String("db", b.name))
+		}
+	case BreakerClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		if b.failures++; b.failures >= b.threshold {
+			b.open()
+		}
+	}
+}
+
+func (b *breaker) open() {
+	b.state = BreakerOpen
+	b.openedAt = time.Now()
+	b.logger.Warn("db circuit breaker opened", zap.String("db", b.name))
+}
+
+// release 放弃半开状态的探测名额，不改变状态，请求没有正常结束（如 panic）时使用
+func (b *breaker) release() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerHalfOpen {
+		b.probing = false
+	}
+}
+
+// do 放行时执行 fn 并记录结果，fn 返回是否遇到了临时错误；不放行时返回 false。
+// fn panic 时释放探测名额，否则熔断器会一直停在半开状态
+func (b *breaker) do(fn func() bool) bool {
+	if !b.allow() {
+		return false
+	}
+	finished := false
+	defer func() {
+		if !finished {
+			b.release()
+		}
+	}()
+	failed := fn()
+	finished = true
+	b.done(failed)
+	return true
+}
+
+// State 当前状态，未开启熔断时为 closed
+func (b *breaker) State() string {
+	if b == nil {
+		return BreakerClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.timeout {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// retryInterceptor 熔断与重试拦截器。事务外的查询遇到临时错误、死锁时按 RetryBackoff 退避重试，
+// 写入不重试；熔断只保护主库，副本上的错误不计入熔断，重试时由 resolver 换一个副本或回落到主库
+func retryInterceptor(callbackName string, config *Config) func(Handler) Handler {
+	b := config.breaker
+	read := callbackName == "gorm:query" || callbackName == "gorm:row"
+	return func(next Handler) Handler {
+		return func(db *gorm.DB) {
+			if db.Error != nil {
+				next(db)
+				return
+			}
+			attempts := 1
+			if _, inTx := db.Statement.ConnPool.(gorm.TxCommitter); read && !inTx {
+				attempts = config.RetryAttempts
+			}
+			ctx := db.Statement.Context
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			for attempt := 1; ; attempt++ {
+				rep := config.resolver.replicaOf(db.Statement.ConnPool)
+				if rep != nil {
+					next(db)
+				} else if !b.do(func() bool {
+					next(db)
+					return db.Error != nil && config.dialect.Transient(db.Error)
+				}) {
+					_ = db.AddError(ErrCircuitOpen)
+					return
+				}
+				err := db.Error
+				transient := err != nil && config.dialect.Transient(err)
+				if err == nil || attempt >= attempts || !(transient || config.dialect.Retryable(err)) {
+					return
+				}
+				if !sleep(ctx, backoff(time.Duration(config.RetryBackoff)*time.Millisecond, attempt)) {
+					return
+				}
+				if rep != nil {
+					config.resolver.reroute(db, rep)
+				}
+				db.Error = nil
+				db.RowsAffected = 0
+			}
+		}
+	}
+}
+
+// BreakerState 熔断器状态：closed、open、half_open
+func (wrap *Wrapper) BreakerState() string {
+	return wrap.breaker.State()
+}
+
+// Health 检查数据库是否可用，熔断器打开时直接返回 ErrCircuitOpen
+func (wrap *Wrapper) Health(ctx context.Context) error {
+	if wrap.breaker.State() == BreakerOpen {
+		return ErrCircuitOpen
+	}
+	db, err := wrap.db.DB()
+	if err != nil {
+		return err
+	}
+	return db.PingContext(ctx)
+}
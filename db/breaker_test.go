@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/xybingbing/pkg/log"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// newTestBreaker 连续 threshold 次临时错误后打开，10ms 后半开
+func newTestBreaker(threshold int) *breaker {
+	b := newBreaker(&Config{dbName: "test", BreakerThreshold: threshold, Logger: &log.Logger{Logger: zap.NewNop()}})
+	b.timeout = 10 * time.Millisecond
+	return b
+}
+
+func TestBreakerStates(t *testing.T) {
+	b := newTestBreaker(2)
+	step := func(failed bool) {
+		t.Helper()
+		if !b.allow() {
+			t.Fatalf("request rejected in %s", b.State())
+		}
+		b.done(failed)
+	}
+
+	// 成功的请求重置连续失败次数
+	step(true)
+	step(false)
+	step(true)
+	if b.State() != BreakerClosed {
+		t.Fatalf("state = %s after non-consecutive failures", b.State())
+	}
+	step(true)
+	if b.State() != BreakerOpen || b.allow() {
+		t.Fatalf("state = %s, want open", b.State())
+	}
+
+	// 半开时只放行一个探测请求，探测失败重新打开
+	time.Sleep(20 * time.Millisecond)
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("state = %s, want half_open", b.State())
+	}
+	if !b.allow() || b.allow() {
+		t.Fatal("half open should let exactly one probe through")
+	}
+	b.done(true)
+	if b.State() != BreakerOpen {
+		t.Fatalf("state = %s after a failed probe", b.State())
+	}
+
+	// 探测 panic 时释放名额，下一个请求可以继续探测
+	time.Sleep(20 * time.Millisecond)
+	func() {
+		defer func() { _ = recover() }()
+		b.do(func() bool { panic("boom") })
+	}()
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("state = %s after a panicking probe", b.State())
+	}
+	if !b.do(func() bool { return false }) || b.State() != BreakerClosed {
+		t.Fatalf("state = %s after a successful probe", b.State())
+	}
+
+	var nilBreaker *breaker
+	if !nilBreaker.allow() || nilBreaker.State() != BreakerClosed {
+		t.Error("nil breaker should allow every request")
+	}
+}
+
+// fakeTx 让 Statement.ConnPool 看起来处于事务中
+type fakeTx struct {
+	gorm.ConnPool
+}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+func TestRetryInterceptor(t *testing.T) {
+	transient := sqlite3.Error{Code: sqlite3.ErrIoErr}
+	busy := sqlite3.Error{Code: sqlite3.ErrBusy}
+	cases := []struct {
+		name     string
+		callback string
+		pool     gorm.ConnPool
+		err      error
+		calls    int
+	}{
+		{"transient read", "gorm:query", nil, transient, 3},
+		{"retryable read", "gorm:row", nil, busy, 3},
+		{"other error", "gorm:query", nil, sql.ErrNoRows, 1},
+		{"write", "gorm:create", nil, transient, 1},
+		{"in transaction", "gorm:query", fakeTx{}, transient, 1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config := &Config{RetryAttempts: 3, RetryBackoff: 1, dialect: sqliteDialect{}}
+			calls := 0
+			handler := retryInterceptor(c.callback, config)(func(db *gorm.DB) {
+				calls++
+				db.Error = c.err
+			})
+			db := &gorm.DB{Config: &gorm.Config{}, Statement: &gorm.Statement{Context: context.Background(), ConnPool: c.pool}}
+			handler(db)
+			if calls != c.calls || !errors.Is(db.Error, c.err) {
+				t.Errorf("%d calls, error %v, want %d calls", calls, db.Error, c.calls)
+			}
+		})
+	}
+
+	// 重试中熔断器打开后不再请求数据库
+	config := &Config{RetryAttempts: 5, RetryBackoff: 1, dialect: sqliteDialect{}, breaker: newTestBreaker(2)}
+	calls := 0
+	handler := retryInterceptor("gorm:query", config)(func(db *gorm.DB) {
+		calls++
+		db.Error = transient
+	})
+	db := &gorm.DB{Config: &gorm.Config{}, Statement: &gorm.Statement{Context: context.Background()}}
+	handler(db)
+	if calls != 2 || !errors.Is(db.Error, ErrCircuitOpen) {
+		t.Errorf("%d calls, error %v, want 2 calls and ErrCircuitOpen", calls, db.Error)
+	}
+}
@@ -0,0 +1,516 @@
+package db
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+)
+
+// ErrCacheMiss 缓存中不存在该 key
+var ErrCacheMiss = errors.New("db: cache miss")
+
+// Cache 查询缓存的存储，语义与 Redis 的 GET、SET PX、INCR 相同，多实例部署时使用 Redis 才能互相失效
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error) // 不存在时返回 ErrCacheMiss
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Incr(ctx context.Context, key string) (int64, error)
+}
+
+type cacheKey struct{}
+
+// cacheSetting 通过 Cached scope 开启缓存时保存在 Statement.Settings 中的 key
+const cacheSetting = "db:cache"
+
+type cacheOptions struct {
+	ttl    time.Duration
+	tables []string
+}
+
+// WithCache 返回开启查询缓存的 context，只对 Find、First、Take、Count 等查询生效，事务中不使用缓存。
+// ttl 为 0 时使用 Config.CacheTTL；tables 为 JOIN、子查询中用到的其他表，这些表写入时缓存同样失效
+func WithCache(ctx context.Context, ttl time.Duration, tables ...string) context.Context {
+	return context.WithValue(ctx, cacheKey{}, &cacheOptions{ttl: ttl, tables: tables})
+}
+
+// Cached 开启查询缓存的 scope，如 db.Scopes(db.Cached(time.Minute)).Find(&list)，参数同 WithCache
+func Cached(ttl time.Duration, tables ...string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Set(cacheSetting, &cacheOptions{ttl: ttl, tables: tables})
+	}
+}
+
+func init() {
+	// 以 map[string]any 为 Dest 时，结果中的时间值需要注册后才能编码
+	gob.Register(time.Time{})
+}
+
+// queryCache 按表的版本号失效的查询缓存：写入表时版本号加一，缓存 key 中包含所有相关表的版本号，旧的缓存自然不再命中
+type queryCache struct {
+	cache  Cache
+	prefix string
+	ttl    time.Duration
+	flight flight
+}
+
+func newQueryCache(config *Config) *queryCache {
+	return &queryCache{
+		cache:  config.Cache,
+		prefix: "gorm:" + config.dbName + ":",
+		ttl:    time.Duration(config.CacheTTL) * time.Second,
+	}
+}
+
+// cacheInterceptor 查询缓存拦截器，查询时读写缓存，写入时使相关表的缓存失效
+func cacheInterceptor(callbackName string, config *Config) func(Handler) Handler {
+	c := config.cache
+	return func(next Handler) Handler {
+		switch callbackName {
+		case "gorm:query":
+			return func(db *gorm.DB) {
+				c.query(db, next)
+			}
+		case "gorm:create", "gorm:update", "gorm:delete":
+			return func(db *gorm.DB) {
+				next(db)
+				if db.Error == nil && db.RowsAffected > 0 && db.Statement.Table != "" {
+					c.written(db.Statement.Context, db.Statement.Table)
+				}
+			}
+		default:
+			return next
+		}
+	}
+}
+
+// options 返回查询的缓存配置，未开启时返回 nil
+func (c *queryCache) options(db *gorm.DB) *cacheOptions {
+	if value, ok := db.Get(cacheSetting); ok {
+		return value.(*cacheOptions)
+	}
+	if ctx := db.Statement.Context; ctx != nil {
+		if opts, ok := ctx.Value(cacheKey{}).(*cacheOptions); ok {
+			return opts
+		}
+	}
+	return nil
+}
+
+func (c *queryCache) query(db *gorm.DB, next Handler) {
+	opts := c.options(db)
+	if opts == nil || db.Error != nil || db.DryRun || db.Statement.Dest == nil {
+		next(db)
+		return
+	}
+	// 事务中可能读到未提交的数据；加锁的查询必须访问数据库
+	if _, ok := db.Statement.ConnPool.(gorm.TxCommitter); ok {
+		next(db)
+		return
+	}
+	if _, ok := db.Statement.Clauses["FOR"]; ok {
+		next(db)
+		return
+	}
+	callbacks.BuildQuerySQL(db)
+	if db.Error != nil {
+		next(db)
+		return
+	}
+	ctx := db.Statement.Context
+	key, err := c.key(ctx, db, opts)
+	if err != nil {
+		log.Printf("db: cache key: %v", err)
+		next(db)
+		return
+	}
+	if data, err := c.cache.Get(ctx, key); err == nil {
+		if c.load(db, data) == nil {
+			return
+		}
+	} else if !errors.Is(err, ErrCacheMiss) {
+		log.Printf("db: cache get: %v", err)
+	}
+
+	// 同一个 key 同时只有一个请求查询数据库，其他请求等待并共用结果
+	data, err, shared := c.flight.do(key, func() ([]byte, error) {
+		next(db)
+		if db.Error != nil {
+			return nil, db.Error
+		}
+		data, err := encodeEntry(db.RowsAffected, db.Statement.Dest)
+		if err != nil {
+			log.Printf("db: cache encode %T: %v", db.Statement.Dest, err)
+			return nil, err
+		}
+		ttl := opts.ttl
+		if ttl <= 0 {
+			ttl = c.ttl
+		}
+		if err = c.cache.Set(ctx, key, data, ttl); err != nil {
+			log.Printf("db: cache set: %v", err)
+		}
+		return data, nil
+	})
+	if !shared {
+		return
+	}
+	if err != nil || c.load(db, data) != nil {
+		next(db)
+	}
+}
+
+// key 由规范化的 SQL、参数、结果类型和相关表的版本号生成
+func (c *queryCache) key(ctx context.Context, db *gorm.DB, opts *cacheOptions) (string, error) {
+	vars, err := json.Marshal(db.Statement.Vars)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%T", normalizeSQL(db.Statement.SQL.String()), vars, db.Statement.Dest)
+	tables := opts.tables
+	if db.Statement.Table != "" {
+		tables = append([]string{db.Statement.Table}, tables...)
+	}
+	for _, table := range tables {
+		version, err := c.version(ctx, table)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "\x00%s=%d", table, version)
+	}
+	return c.prefix + "q:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// encodeEntry 以 gob 编码影响的行数和 Dest。gob 与 gorm 扫描结果一样只处理导出字段，不受 json 标签影响
+func encodeEntry(rows int64, dest any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	if err := enc.Encode(rows); err != nil {
+		return nil, err
+	}
+	if err := enc.Encode(dest); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// load 将缓存的结果写入 Dest
+func (c *queryCache) load(db *gorm.DB, data []byte) error {
+	dest := reflect.ValueOf(db.Statement.Dest)
+	if dest.Kind() != reflect.Pointer || dest.IsNil() {
+		return fmt.Errorf("db: cache dest %T is not a pointer", db.Statement.Dest)
+	}
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	var rows int64
+	if err := dec.Decode(&rows); err != nil {
+		return err
+	}
+	// gob 不传输零值字段，先清空 Dest，避免保留调用方原有的值
+	dest.Elem().SetZero()
+	if err := dec.Decode(db.Statement.Dest); err != nil {
+		return err
+	}
+	db.RowsAffected = rows
+	if rows == 0 && db.Statement.RaiseErrorOnNotFound {
+		_ = db.AddError(gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// version 表的版本号，不存在时为 0
+func (c *queryCache) version(ctx context.Context, table string) (int64, error) {
+	data, err := c.cache.Get(ctx, c.prefix+"v:"+table)
+	if errors.Is(err, ErrCacheMiss) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(data), 10, 64)
+}
+
+// written 表被写入，事务中推迟到提交后失效，避免提交前的查询把旧数据重新缓存
+func (c *queryCache) written(ctx context.Context, table string) {
+	if ctx != nil {
+		if dirty, ok := ctx.Value(dirtyKey{}).(*dirtyTables); ok {
+			dirty.add(table)
+			return
+		}
+	}
+	if err := c.invalidate(ctx, table); err != nil {
+		log.Printf("db: cache invalidate %s: %v", table, err)
+	}
+}
+
+// invalidate 使表的缓存失效
+func (c *queryCache) invalidate(ctx context.Context, tables ...string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var errs []error
+	for _, table := range tables {
+		if _, err := c.cache.Incr(ctx, c.prefix+"v:"+table); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Invalidate 使表的查询缓存失效，用于 Exec 等无法识别表的写入，未配置 Cache 时不做任何事
+func (wrap *Wrapper) Invalidate(ctx context.Context, tables ...string) error {
+	if wrap.cache == nil {
+		return nil
+	}
+	return wrap.cache.invalidate(ctx, tables...)
+}
+
+type dirtyKey struct{}
+
+// dirtyTables 事务中写入过的表
+type dirtyTables struct {
+	mu     sync.Mutex
+	tables map[string]struct{}
+}
+
+func (d *dirtyTables) add(table string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.tables == nil {
+		d.tables = map[string]struct{}{}
+	}
+	d.tables[table] = struct{}{}
+}
+
+func (d *dirtyTables) list() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	tables := make([]string, 0, len(d.tables))
+	for table := range d.tables {
+		tables = append(tables, table)
+	}
+	return tables
+}
+
+// normalizeSQL 去掉注释（如链路追踪的 traceId）并合并空白，引号内的内容保持不变
+func normalizeSQL(sql string) string {
+	var (
+		buf   strings.Builder
+		quote byte
+		space bool
+	)
+	for i := 0; i < len(sql); i++ {
+		ch := sql[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 3
+			}
+			space = true
+			continue
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			space = true
+			continue
+		}
+		if space && buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		space = false
+		buf.WriteByte(ch)
+	}
+	return buf.String()
+}
+
+// flight 合并同一个 key 的并发查询
+type flight struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	wg   sync.WaitGroup
+	data []byte
+	err  error
+}
+
+// do 执行 fn，已有相同 key 在执行时等待其结果，shared 表示结果来自其他调用
+func (f *flight) do(key string, fn func() ([]byte, error)) (data []byte, err error, shared bool) {
+	f.mu.Lock()
+	if f.calls == nil {
+		f.calls = map[string]*flightCall{}
+	}
+	if call, ok := f.calls[key]; ok {
+		f.mu.Unlock()
+		call.wg.Wait()
+		return call.data, call.err, true
+	}
+	call := &flightCall{}
+	call.wg.Add(1)
+	f.calls[key] = call
+	f.mu.Unlock()
+
+	defer func() {
+		call.wg.Done()
+		f.mu.Lock()
+		delete(f.calls, key)
+		f.mu.Unlock()
+	}()
+	call.data, call.err = fn()
+	return call.data, call.err, false
+}
+
+//================================================================================
+
+// lruCache 进程内的 LRU 缓存
+type lruCache struct {
+	mu       sync.Mutex
+	size     int
+	ll       *list.List
+	items    map[string]*list.Element
+	counters map[string]int64
+}
+
+type lruItem struct {
+	key      string
+	value    []byte
+	expireAt time.Time
+}
+
+// NewLRUCache 进程内的 LRU 缓存，最多保存 size 条查询结果。表的版本号单独保存，不会被淘汰
+func NewLRUCache(size int) Cache {
+	return &lruCache{
+		size:     size,
+		ll:       list.New(),
+		items:    map[string]*list.Element{},
+		counters: map[string]int64{},
+	}
+}
+
+func (c *lruCache) Get(ctx context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n, ok := c.counters[key]; ok {
+		return strconv.AppendInt(nil, n, 10), nil
+	}
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	item := elem.Value.(*lruItem)
+	if !item.expireAt.IsZero() && time.Now().After(item.expireAt) {
+		c.ll.Remove(elem)
+		delete(c.items, key)
+		return nil, ErrCacheMiss
+	}
+	c.ll.MoveToFront(elem)
+	return item.value, nil
+}
+
+func (c *lruCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item := &lruItem{key: key, value: value}
+	if ttl > 0 {
+		item.expireAt = time.Now().Add(ttl)
+	}
+	if elem, ok := c.items[key]; ok {
+		elem.Value = item
+		c.ll.MoveToFront(elem)
+		return nil
+	}
+	c.items[key] = c.ll.PushFront(item)
+	for c.size > 0 && c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruItem).key)
+	}
+	return nil
+}
+
+func (c *lruCache) Incr(ctx context.Context, key string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counters[key]++
+	return c.counters[key], nil
+}
+
+//================================================================================
+
+// RedisDo 执行一条 Redis 命令，如 go-redis 的
+// func(ctx context.Context, args ...any) (any, error) { return client.Do(ctx, args...).Result() }
+type RedisDo func(ctx context.Context, args ...any) (any, error)
+
+// redisCache 基于 Redis 命令的缓存，不依赖具体的客户端
+type redisCache struct {
+	do     RedisDo
+	nilErr error
+}
+
+// NewRedisCache 使用 do 执行命令的缓存，nilErr 为客户端表示 key 不存在的错误（如 go-redis 的 redis.Nil），
+// 客户端以 nil 结果表示不存在时传 nil
+func NewRedisCache(do RedisDo, nilErr error) Cache {
+	return &redisCache{do: do, nilErr: nilErr}
+}
+
+func (c *redisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	reply, err := c.do(ctx, "GET", key)
+	if err != nil {
+		if c.nilErr != nil && errors.Is(err, c.nilErr) {
+			return nil, ErrCacheMiss
+		}
+		return nil, err
+	}
+	switch v := reply.(type) {
+	case nil:
+		return nil, ErrCacheMiss
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	default:
+		return nil, fmt.Errorf("db: unexpected redis reply %T", reply)
+	}
+}
+
+func (c *redisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []any{"SET", key, value}
+	if ttl > 0 {
+		args = append(args, "PX", ttl.Milliseconds())
+	}
+	_, err := c.do(ctx, args...)
+	return err
+}
+
+func (c *redisCache) Incr(ctx context.Context, key string) (int64, error) {
+	reply, err := c.do(ctx, "INCR", key)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("db: unexpected redis reply %T", reply)
+	}
+	return n, nil
+}
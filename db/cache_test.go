@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type testOrder struct {
+	ID       uint64 `gorm:"primaryKey"`
+	Title    string
+	Secret   string `json:"-"`
+	Amount   float64
+	Note     *string
+	PaidAt   time.Time
+	Internal []byte `json:"-"`
+}
+
+func TestCacheHit(t *testing.T) {
+	wrap := newTestWrapper(t, &Config{Cache: NewLRUCache(100), CacheTTL: 60})
+	db := wrap.GetDB()
+	if err := db.AutoMigrate(&testOrder{}); err != nil {
+		t.Fatal(err)
+	}
+	note := "gift"
+	paidAt := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	orders := []testOrder{
+		{Title: "a", Secret: "s1", Amount: 1.5, Note: &note, PaidAt: paidAt, Internal: []byte{1, 2}},
+		{Title: "b", Secret: "s2", Amount: 2},
+	}
+	if err := db.Create(&orders).Error; err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithCache(context.Background(), time.Minute)
+
+	var miss, hit []testOrder
+	if err := GetSession(ctx, db).Order("id").Find(&miss).Error; err != nil {
+		t.Fatal(err)
+	}
+	// Exec 不会使缓存失效，再次查询仍返回缓存的结果
+	if err := db.Exec("UPDATE test_orders SET title = ?", "changed").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := GetSession(ctx, db).Order("id").Find(&hit).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hit, miss) {
+		t.Errorf("hit  %+v\nmiss %+v", hit, miss)
+	}
+	if miss[0].Secret != "s1" || string(miss[0].Internal) != "\x01\x02" {
+		t.Errorf("json:\"-\" fields lost: %+v", miss[0])
+	}
+
+	// 命中时清空调用方原有的值
+	var order testOrder
+	stale := testOrder{Title: "stale", Note: &note}
+	for i, dest := range []*testOrder{&order, &stale} {
+		if err := GetSession(ctx, db).Where("id = ?", orders[1].ID).Take(dest).Error; err != nil {
+			t.Fatal(err)
+		}
+		if i > 0 && !reflect.DeepEqual(*dest, order) {
+			t.Errorf("hit %+v, miss %+v", *dest, order)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		err := GetSession(ctx, db).Where("id = ?", 100).Take(&order).Error
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("take missing row %d: %v", i, err)
+		}
+		var total int64
+		if err = GetSession(ctx, db).Model(&testOrder{}).Count(&total).Error; err != nil || total != 2 {
+			t.Errorf("count %d = %d, %v", i, total, err)
+		}
+	}
+
+	// 写入后缓存失效
+	if err := db.Model(&testOrder{}).Where("id = ?", orders[0].ID).Update("amount", 3).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := GetSession(ctx, db).Order("id").Find(&hit).Error; err != nil {
+		t.Fatal(err)
+	}
+	if hit[0].Title != "changed" || hit[0].Amount != 3 {
+		t.Errorf("after update got %+v", hit[0])
+	}
+}
@@ -0,0 +1,254 @@
+package db
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrInvalidCursor 游标被篡改、已过期（排序变化）或格式错误
+var ErrInvalidCursor = errors.New("db: invalid cursor")
+
+// ErrPaginatorKey Paginator 没有配置签名密钥
+var ErrPaginatorKey = errors.New("db: paginator key is required")
+
+// Cursor 游标分页的请求参数，After、Before 为上一页返回的 Next、Prev，最多指定一个
+type Cursor struct {
+	After     string `json:"after" form:"after"`
+	Before    string `json:"before" form:"before"`
+	Limit     int    `json:"limit" form:"limit"`
+	OrderBy   string `json:"order_by" form:"order_by"`
+	WithTotal bool   `json:"with_total" form:"with_total"`
+}
+
+// PageResult 分页结果
+type PageResult[T any] struct {
+	Items []T    `json:"items"`
+	Next  string `json:"next,omitempty"`  // 下一页的游标，没有下一页时为空
+	Prev  string `json:"prev,omitempty"`  // 上一页的游标，没有上一页时为空
+	Total *int64 `json:"total,omitempty"` // 总数，仅 WithTotal 时返回
+}
+
+// Paginator 游标分页的配置，通常每个列表接口一个
+type Paginator struct {
+	Key      []byte  // 游标签名密钥，防止客户端伪造游标，必填
+	Columns  Columns // 允许排序的字段
+	Unique   string  // 唯一列，总是作为最后一个排序字段保证顺序稳定，默认 id
+	Limit    int     // 默认每页数量，默认 20
+	MaxLimit int     // 每页最大数量，默认 100
+}
+
+// cursorToken 游标中保存的内容
+type cursorToken struct {
+	Order  string            `json:"o"`
+	Values []json.RawMessage `json:"v"`
+}
+
+// Paginate 基于游标（keyset）分页，session 上可以带查询条件。
+// 翻页使用 WHERE (排序列) > (上一页最后一行) 代替 OFFSET，性能不随页数下降。c 为 nil 时返回第一页
+func Paginate[T any](session *gorm.DB, p *Paginator, c *Cursor) (*PageResult[T], error) {
+	if len(p.Key) == 0 {
+		return nil, ErrPaginatorKey
+	}
+	if c == nil {
+		c = &Cursor{}
+	}
+	if c.After != "" && c.Before != "" {
+		return nil, errors.New("db: after and before are mutually exclusive")
+	}
+	orders, err := p.orders(c.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+	limit := c.Limit
+	if limit <= 0 {
+		limit = p.Limit
+		if limit <= 0 {
+			limit = 20
+		}
+	}
+	maxLimit := p.MaxLimit
+	if maxLimit <= 0 {
+		maxLimit = 100
+	}
+	limit = min(limit, maxLimit)
+
+	base := session.Session(&gorm.Session{})
+	stmt := &gorm.Statement{DB: base}
+	if err = stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	fields := make([]*schema.Field, len(orders))
+	for i, order := range orders {
+		if fields[i] = stmt.Schema.LookUpField(order.Column); fields[i] == nil {
+			return nil, fmt.Errorf("db: unknown column %q in %s", order.Column, stmt.Schema.Name)
+		}
+	}
+
+	result := &PageResult[T]{}
+	if c.WithTotal {
+		var total int64
+		if err = base.Model(new(T)).Count(&total).Error; err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	backward := c.Before != ""
+	query := base.Model(new(T)).Limit(limit + 1)
+	if token := c.After + c.Before; token != "" {
+		values, err := p.decode(token, orders, fields)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(keysetClause(orders, values, backward))
+	}
+	if backward {
+		query = query.Clauses(orderClause(reverse(orders)))
+	} else {
+		query = query.Clauses(orderClause(orders))
+	}
+	if err = query.Find(&result.Items).Error; err != nil {
+		return nil, err
+	}
+
+	more := len(result.Items) > limit
+	if more {
+		result.Items = result.Items[:limit]
+	}
+	if backward {
+		for i, j := 0, len(result.Items)-1; i < j; i, j = i+1, j-1 {
+			result.Items[i], result.Items[j] = result.Items[j], result.Items[i]
+		}
+	}
+	if len(result.Items) == 0 {
+		return result, nil
+	}
+	// 向后翻页时 more 表示前面还有数据，向前翻页时表示后面还有数据
+	hasNext, hasPrev := more, c.After != ""
+	if backward {
+		hasNext, hasPrev = true, more
+	}
+	ctx := base.Statement.Context
+	if hasNext {
+		if result.Next, err = p.encode(ctx, orders, fields, result.Items[len(result.Items)-1]); err != nil {
+			return nil, err
+		}
+	}
+	if hasPrev {
+		if result.Prev, err = p.encode(ctx, orders, fields, result.Items[0]); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// orders 解析排序并追加唯一列
+func (p *Paginator) orders(orderBy string) ([]Order, error) {
+	unique := p.Unique
+	if unique == "" {
+		unique = "id"
+	}
+	orders, err := ParseOrderBy(orderBy, p.Columns)
+	if err != nil {
+		return nil, err
+	}
+	for _, order := range orders {
+		if order.Column == unique {
+			return orders, nil
+		}
+	}
+	return append(orders, Order{Column: unique}), nil
+}
+
+// encode 将行的排序列的值编码为签名后的游标
+func (p *Paginator) encode(ctx context.Context, orders []Order, fields []*schema.Field, item any) (string, error) {
+	token := cursorToken{Order: orderString(orders)}
+	rv := reflect.ValueOf(item)
+	for _, f := range fields {
+		value, _ := f.ValueOf(ctx, rv)
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		token.Values = append(token.Values, raw)
+	}
+	payload, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(p.sign(payload)), nil
+}
+
+// decode 校验签名并按字段类型还原游标中的值
+func (p *Paginator) decode(cursor string, orders []Order, fields []*schema.Field) ([]any, error) {
+	encoded, sig, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, p.sign(payload)) {
+		return nil, ErrInvalidCursor
+	}
+	var token cursorToken
+	if err = json.Unmarshal(payload, &token); err != nil || token.Order != orderString(orders) || len(token.Values) != len(fields) {
+		return nil, ErrInvalidCursor
+	}
+	values := make([]any, len(fields))
+	for i, f := range fields {
+		value := reflect.New(f.FieldType)
+		if err = json.Unmarshal(token.Values[i], value.Interface()); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values[i] = value.Elem().Interface()
+	}
+	return values, nil
+}
+
+func (p *Paginator) sign(payload []byte) []byte {
+	h := hmac.New(sha256.New, p.Key)
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+// keysetClause 生成 (a, b) > (x, y) 的展开形式：a > x OR (a = x AND b > y)，支持每列不同的排序方向
+func keysetClause(orders []Order, values []any, backward bool) clause.Expression {
+	var or []clause.Expression
+	for i, order := range orders {
+		var and []clause.Expression
+		for j := 0; j < i; j++ {
+			and = append(and, clause.Eq{Column: clause.Column{Name: orders[j].Column}, Value: values[j]})
+		}
+		column := clause.Column{Name: order.Column}
+		if order.Desc != backward {
+			and = append(and, clause.Lt{Column: column, Value: values[i]})
+		} else {
+			and = append(and, clause.Gt{Column: column, Value: values[i]})
+		}
+		or = append(or, clause.And(and...))
+	}
+	return clause.Or(or...)
+}
+
+// reverse 反转排序方向，向前翻页时使用
+func reverse(orders []Order) []Order {
+	reversed := make([]Order, len(orders))
+	for i, order := range orders {
+		reversed[i] = Order{Column: order.Column, Desc: !order.Desc}
+	}
+	return reversed
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// seedAges 按顺序插入 age 为 ages 的记录，id 从 1 开始
+func seedAges(t *testing.T, db *gorm.DB, ages ...int) {
+	t.Helper()
+	for i, age := range ages {
+		if err := db.Create(&testUser{ID: uint64(i + 1), Name: "user", Age: age}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func pageIDs(items []testUser) []uint64 {
+	ids := make([]uint64, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	return ids
+}
+
+func TestPaginate(t *testing.T) {
+	db := newTestWrapper(t, &Config{}).db
+	// age 有重复，按 -age 排序时由 id 决定顺序：1 3 5 | 2 4 7 | 6
+	seedAges(t, db, 30, 20, 30, 20, 30, 10, 20)
+	p := &Paginator{Key: []byte("secret"), Columns: AllowColumns("age", "id"), MaxLimit: 2}
+
+	var pages [][]uint64
+	var last *PageResult[testUser]
+	c := &Cursor{OrderBy: "-age", Limit: 3, WithTotal: true}
+	for {
+		page, err := Paginate[testUser](db, p, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total == nil || *page.Total != 7 {
+			t.Fatalf("total = %v", page.Total)
+		}
+		if (len(pages) == 0) != (page.Prev == "") {
+			t.Errorf("page %d prev = %q", len(pages), page.Prev)
+		}
+		pages = append(pages, pageIDs(page.Items))
+		last = page
+		if page.Next == "" {
+			break
+		}
+		c = &Cursor{OrderBy: "-age", Limit: 3, After: page.Next, WithTotal: true}
+	}
+	// Limit 超过 MaxLimit 时按 MaxLimit 分页
+	want := [][]uint64{{1, 3}, {5, 2}, {4, 7}, {6}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Fatalf("forward pages %v, want %v", pages, want)
+	}
+
+	// 从最后一页向前翻
+	pages = nil
+	for prev := last.Prev; prev != ""; {
+		page, err := Paginate[testUser](db, p, &Cursor{OrderBy: "-age", Before: prev})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Next == "" {
+			t.Errorf("backward page %v has no next", pageIDs(page.Items))
+		}
+		pages = append(pages, pageIDs(page.Items))
+		prev = page.Prev
+	}
+	want = [][]uint64{{4, 7}, {5, 2}, {1, 3}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("backward pages %v, want %v", pages, want)
+	}
+
+	// 查询条件和默认排序
+	page, err := Paginate[testUser](db.Where("age = ?", 20), &Paginator{Key: p.Key}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pageIDs(page.Items); !reflect.DeepEqual(got, []uint64{2, 4, 7}) || page.Next != "" || page.Prev != "" {
+		t.Errorf("filtered page %v, next %q, prev %q", got, page.Next, page.Prev)
+	}
+}
+
+func TestPaginateInvalidCursor(t *testing.T) {
+	db := newTestWrapper(t, &Config{}).db
+	seedAges(t, db, 30, 20, 10)
+	p := &Paginator{Key: []byte("secret"), Columns: AllowColumns("age", "name")}
+	page, err := Paginate[testUser](db, p, &Cursor{OrderBy: "age", Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := page.Next
+	encoded, sig, _ := strings.Cut(next, ".")
+	tampered := []byte(encoded)
+	tampered[len(tampered)/2] ^= 1
+
+	cases := []struct {
+		name string
+		p    *Paginator
+		c    *Cursor
+		want error
+	}{
+		{"wrong key", &Paginator{Key: []byte("other"), Columns: p.Columns}, &Cursor{OrderBy: "age", After: next}, ErrInvalidCursor},
+		{"tampered payload", p, &Cursor{OrderBy: "age", After: string(tampered) + "." + sig}, ErrInvalidCursor},
+		{"missing signature", p, &Cursor{OrderBy: "age", After: encoded}, ErrInvalidCursor},
+		{"not base64", p, &Cursor{OrderBy: "age", Before: "!!." + sig}, ErrInvalidCursor},
+		{"different order", p, &Cursor{OrderBy: "-age", After: next}, ErrInvalidCursor},
+		{"no key", &Paginator{}, nil, ErrPaginatorKey},
+	}
+	for _, c := range cases {
+		if _, err = Paginate[testUser](db, c.p, c.c); !errors.Is(err, c.want) {
+			t.Errorf("%s: error = %v, want %v", c.name, err, c.want)
+		}
+	}
+
+	for name, c := range map[string]*Cursor{
+		"after and before":  {After: next, Before: next},
+		"not sortable":      {OrderBy: "version"},
+		"not in the schema": {OrderBy: "id"},
+	} {
+		p := p
+		if name == "not in the schema" {
+			p = &Paginator{Key: p.Key, Columns: Columns{"id": "id"}, Unique: "uuid"}
+		}
+		if _, err = Paginate[testUser](db, p, c); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
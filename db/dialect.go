@@ -0,0 +1,387 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Queryer 执行单行查询，*sql.DB、*sql.Conn、*sql.Tx 均满足
+type Queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Dialect 屏蔽不同数据库之间的差异，db 包中不再直接写某种数据库特有的 SQL
+type Dialect interface {
+	// Name 数据库类型，与 Config.Type 一致
+	Name() string
+	// Open 创建 gorm 使用的驱动
+	Open(dsn string) gorm.Dialector
+	// CurrentDatabase 当前连接的数据库名
+	CurrentDatabase(ctx context.Context, q Queryer) (string, error)
+	// CurrentSchema 当前的 schema，没有 schema 概念的数据库与数据库名相同
+	CurrentSchema(ctx context.Context, q Queryer) (string, error)
+	// ServerVersion 数据库服务端版本
+	ServerVersion(ctx context.Context, q Queryer) (string, error)
+	// ReadOnly 当前连接是否只读，如只读副本或开启了只读模式
+	ReadOnly(ctx context.Context, q Queryer) (bool, error)
+	// RowLock 行锁子句，strength 为 clause.LockingStrengthUpdate 或 clause.LockingStrengthShare，不支持行锁时返回 nil
+	RowLock(strength string) clause.Expression
+	// Upsert 冲突时更新 updates 列的子句，updates 为空时忽略冲突
+	Upsert(conflict []string, updates []string) clause.Expression
+	// Lock 获取会话级的命名锁，阻塞直到获取成功，必须在同一个连接上 Unlock
+	Lock(ctx context.Context, conn *sql.Conn, name string) error
+	// Unlock 释放 Lock 获取的锁
+	Unlock(ctx context.Context, conn *sql.Conn, name string) error
+	// Retryable 是否为死锁、序列化失败等重试整个事务即可解决的错误
+	Retryable(err error) bool
+	// Explain 返回查看 query 执行计划的 SQL
+	Explain(query string) string
+	// Transient 是否为连接断开、连接数过多、主从切换后只读等短暂的错误，这类错误会计入熔断
+	Transient(err error) bool
+}
+
+// lazyDialect 打开时需要额外关闭连接检查的方言，用于启动时可能不可用的副本
+type lazyDialect interface {
+	OpenLazy(dsn string) gorm.Dialector
+}
+
+// openLazy 打开时不访问数据库的驱动
+func openLazy(dialect Dialect, dsn string) gorm.Dialector {
+	if lazy, ok := dialect.(lazyDialect); ok {
+		return lazy.OpenLazy(dsn)
+	}
+	return dialect.Open(dsn)
+}
+
+var dialects = sync.Map{}
+
+func init() {
+	RegisterDialect(TypeMySQL, mysqlDialect{})
+	RegisterDialect(TypePostgreSQL, postgresDialect{})
+	RegisterDialect(TypeSQLite, sqliteDialect{})
+	RegisterDialect("sqlite", sqliteDialect{})
+}
+
+// RegisterDialect 注册数据库方言，name 对应 Config.Type，可覆盖内置实现
+func RegisterDialect(name string, dialect Dialect) {
+	dialects.Store(name, dialect)
+}
+
+// GetDialect 返回 name 对应的方言
+func GetDialect(name string) (Dialect, error) {
+	if value, ok := dialects.Load(name); ok {
+		return value.(Dialect), nil
+	}
+	return nil, fmt.Errorf("db: unsupported type %q", name)
+}
+
+// queryString 执行返回单个字符串的查询，NULL 返回空字符串
+func queryString(ctx context.Context, q Queryer, query string) (string, error) {
+	var s sql.NullString
+	if err := q.QueryRowContext(ctx, query).Scan(&s); err != nil {
+		return "", err
+	}
+	return s.String, nil
+}
+
+// upsert OnConflict 在各个驱动中都会生成对应的语法
+func upsert(conflict []string, updates []string) clause.Expression {
+	onConflict := clause.OnConflict{}
+	for _, column := range conflict {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: column})
+	}
+	if len(updates) == 0 {
+		onConflict.DoNothing = true
+	} else {
+		onConflict.DoUpdates = clause.AssignmentColumns(updates)
+	}
+	return onConflict
+}
+
+// connError 各个驱动通用的连接错误，调用方取消或超时不算
+func connError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.As(err, &netErr)
+}
+
+//================================================================================
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string {
+	return TypeMySQL
+}
+
+func (mysqlDialect) Open(dsn string) gorm.Dialector {
+	return mysql.Open(dsn)
+}
+
+// OpenLazy 跳过初始化时的 SELECT VERSION()
+func (mysqlDialect) OpenLazy(dsn string) gorm.Dialector {
+	return mysql.New(mysql.Config{DSN: dsn, SkipInitializeWithVersion: true})
+}
+
+func (mysqlDialect) CurrentDatabase(ctx context.Context, q Queryer) (string, error) {
+	return queryString(ctx, q, "SELECT DATABASE()")
+}
+
+func (d mysqlDialect) CurrentSchema(ctx context.Context, q Queryer) (string, error) {
+	return d.CurrentDatabase(ctx, q)
+}
+
+func (mysqlDialect) ServerVersion(ctx context.Context, q Queryer) (string, error) {
+	return queryString(ctx, q, "SELECT VERSION()")
+}
+
+func (mysqlDialect) ReadOnly(ctx context.Context, q Queryer) (bool, error) {
+	var readOnly bool
+	err := q.QueryRowContext(ctx, "SELECT @@global.read_only OR @@session.transaction_read_only").Scan(&readOnly)
+	if err != nil {
+		// MySQL 5.7.20 之前及 MariaDB 使用 tx_read_only
+		err = q.QueryRowContext(ctx, "SELECT @@global.read_only OR @@session.tx_read_only").Scan(&readOnly)
+	}
+	return readOnly, err
+}
+
+func (mysqlDialect) RowLock(strength string) clause.Expression {
+	return clause.Locking{Strength: strength}
+}
+
+func (mysqlDialect) Explain(query string) string {
+	return "EXPLAIN " + query
+}
+
+func (mysqlDialect) Upsert(conflict []string, updates []string) clause.Expression {
+	return upsert(conflict, updates)
+}
+
+func (mysqlDialect) Lock(ctx context.Context, conn *sql.Conn, name string) error {
+	var ok sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, -1)", name).Scan(&ok); err != nil {
+		return err
+	}
+	if ok.Int64 != 1 {
+		return fmt.Errorf("db: get lock %q failed", name)
+	}
+	return nil
+}
+
+func (mysqlDialect) Unlock(ctx context.Context, conn *sql.Conn, name string) error {
+	_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", name)
+	return err
+}
+
+// Retryable 1213 死锁，1205 等待锁超时
+func (mysqlDialect) Retryable(err error) bool {
+	var mysqlErr *mysqlDriver.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1213 || mysqlErr.Number == 1205
+	}
+	return false
+}
+
+// Transient 1040 连接数过多，1053 服务关闭中，1290、1836 只读模式，1792 只读事务
+func (mysqlDialect) Transient(err error) bool {
+	var mysqlErr *mysqlDriver.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1040, 1053, 1290, 1792, 1836:
+			return true
+		}
+		return false
+	}
+	return errors.Is(err, mysqlDriver.ErrInvalidConn) || connError(err)
+}
+
+//================================================================================
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return TypePostgreSQL
+}
+
+func (postgresDialect) Open(dsn string) gorm.Dialector {
+	return postgres.Open(dsn)
+}
+
+func (postgresDialect) CurrentDatabase(ctx context.Context, q Queryer) (string, error) {
+	return queryString(ctx, q, "SELECT current_database()")
+}
+
+func (postgresDialect) CurrentSchema(ctx context.Context, q Queryer) (string, error) {
+	return queryString(ctx, q, "SELECT current_schema()")
+}
+
+func (postgresDialect) ServerVersion(ctx context.Context, q Queryer) (string, error) {
+	return queryString(ctx, q, "SHOW server_version")
+}
+
+func (postgresDialect) ReadOnly(ctx context.Context, q Queryer) (bool, error) {
+	var readOnly bool
+	err := q.QueryRowContext(ctx, "SELECT pg_is_in_recovery() OR current_setting('transaction_read_only') = 'on'").Scan(&readOnly)
+	return readOnly, err
+}
+
+func (postgresDialect) RowLock(strength string) clause.Expression {
+	return clause.Locking{Strength: strength}
+}
+
+func (postgresDialect) Explain(query string) string {
+	return "EXPLAIN " + query
+}
+
+func (postgresDialect) Upsert(conflict []string, updates []string) clause.Expression {
+	return upsert(conflict, updates)
+}
+
+func (postgresDialect) Lock(ctx context.Context, conn *sql.Conn, name string) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey(name))
+	return err
+}
+
+func (postgresDialect) Unlock(ctx context.Context, conn *sql.Conn, name string) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey(name))
+	return err
+}
+
+// Retryable 40001 序列化失败，40P01 死锁
+func (postgresDialect) Retryable(err error) bool {
+	switch sqlState(err) {
+	case "40001", "40P01":
+		return true
+	}
+	return false
+}
+
+// Transient 08 类连接异常，53300 连接数过多，57P01~57P03 服务关闭或启动中，25006 只读事务
+func (postgresDialect) Transient(err error) bool {
+	switch state := sqlState(err); {
+	case strings.HasPrefix(state, "08"):
+		return true
+	case state == "53300", state == "57P01", state == "57P02", state == "57P03", state == "25006":
+		return true
+	case state != "":
+		return false
+	}
+	return connError(err)
+}
+
+// sqlState 返回 pgx 等驱动错误中的 SQLSTATE
+func sqlState(err error) string {
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		return stateErr.SQLState()
+	}
+	return ""
+}
+
+// lockKey Postgres 的 advisory lock 使用整数作为锁名
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+//================================================================================
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return TypeSQLite
+}
+
+func (sqliteDialect) Open(dsn string) gorm.Dialector {
+	return sqlite.Open(dsn)
+}
+
+// CurrentDatabase 返回主数据库文件名（不含扩展名），内存数据库返回 main
+func (sqliteDialect) CurrentDatabase(ctx context.Context, q Queryer) (string, error) {
+	file, err := queryString(ctx, q, "SELECT file FROM pragma_database_list WHERE name = 'main'")
+	if err != nil || file == "" {
+		return "main", err
+	}
+	base := filepath.Base(file)
+	return strings.TrimSuffix(base, filepath.Ext(base)), nil
+}
+
+func (sqliteDialect) CurrentSchema(context.Context, Queryer) (string, error) {
+	return "main", nil
+}
+
+func (sqliteDialect) ServerVersion(ctx context.Context, q Queryer) (string, error) {
+	return queryString(ctx, q, "SELECT sqlite_version()")
+}
+
+func (sqliteDialect) ReadOnly(ctx context.Context, q Queryer) (bool, error) {
+	var readOnly bool
+	err := q.QueryRowContext(ctx, "PRAGMA query_only").Scan(&readOnly)
+	return readOnly, err
+}
+
+// RowLock SQLite 只有库级锁，不支持行锁
+func (sqliteDialect) RowLock(string) clause.Expression {
+	return nil
+}
+
+func (sqliteDialect) Explain(query string) string {
+	return "EXPLAIN QUERY PLAN " + query
+}
+
+func (sqliteDialect) Upsert(conflict []string, updates []string) clause.Expression {
+	return upsert(conflict, updates)
+}
+
+// Lock SQLite 写操作本身是串行的，命名锁不做任何事
+func (sqliteDialect) Lock(context.Context, *sql.Conn, string) error {
+	return nil
+}
+
+func (sqliteDialect) Unlock(context.Context, *sql.Conn, string) error {
+	return nil
+}
+
+// Retryable 数据库文件或表被锁定
+func (sqliteDialect) Retryable(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+	return false
+}
+
+// Transient SQLite 没有网络连接，只有数据库文件无法打开等情况
+func (sqliteDialect) Transient(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrCantOpen || sqliteErr.Code == sqlite3.ErrIoErr
+	}
+	return connError(err)
+}
@@ -0,0 +1,401 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// 过滤操作符
+const (
+	OpEq      = "eq"
+	OpNe      = "ne"
+	OpGt      = "gt"
+	OpGte     = "gte"
+	OpLt      = "lt"
+	OpLte     = "lte"
+	OpIn      = "in"
+	OpNotIn   = "not_in"
+	OpLike    = "like"   // 包含，value 中的 % 和 _ 按普通字符处理
+	OpPrefix  = "prefix" // 前缀匹配
+	OpBetween = "between"
+	OpIsNull  = "is_null" // value 为 true 时 IS NULL，false 时 IS NOT NULL
+)
+
+// Filter 过滤条件，叶子节点为 Field Op Value，And、Or 为条件组，可以任意嵌套。
+// JSON 形式如 {"or":[{"field":"status","op":"in","value":["a","b"]},{"field":"age","op":"gt","value":18}]}
+type Filter struct {
+	Field string   `json:"field,omitempty"`
+	Op    string   `json:"op,omitempty"`
+	Value any      `json:"value,omitempty"`
+	And   []Filter `json:"and,omitempty"`
+	Or    []Filter `json:"or,omitempty"`
+}
+
+// 常用条件的构造函数
+func Eq(field string, value any) Filter  { return Filter{Field: field, Op: OpEq, Value: value} }
+func Ne(field string, value any) Filter  { return Filter{Field: field, Op: OpNe, Value: value} }
+func Gt(field string, value any) Filter  { return Filter{Field: field, Op: OpGt, Value: value} }
+func Gte(field string, value any) Filter { return Filter{Field: field, Op: OpGte, Value: value} }
+func Lt(field string, value any) Filter  { return Filter{Field: field, Op: OpLt, Value: value} }
+func Lte(field string, value any) Filter { return Filter{Field: field, Op: OpLte, Value: value} }
+
+func In(field string, values ...any) Filter {
+	return Filter{Field: field, Op: OpIn, Value: values}
+}
+
+func NotIn(field string, values ...any) Filter {
+	return Filter{Field: field, Op: OpNotIn, Value: values}
+}
+
+func Like(field, value string) Filter   { return Filter{Field: field, Op: OpLike, Value: value} }
+func Prefix(field, value string) Filter { return Filter{Field: field, Op: OpPrefix, Value: value} }
+
+func Between(field string, low, high any) Filter {
+	return Filter{Field: field, Op: OpBetween, Value: []any{low, high}}
+}
+
+func IsNull(field string, null bool) Filter {
+	return Filter{Field: field, Op: OpIsNull, Value: null}
+}
+
+func And(filters ...Filter) Filter { return Filter{And: filters} }
+func Or(filters ...Filter) Filter  { return Filter{Or: filters} }
+
+// Empty 是否没有任何条件
+func (f Filter) Empty() bool {
+	return f.Field == "" && len(f.And) == 0 && len(f.Or) == 0
+}
+
+// Apply 将过滤条件加到 session 上，字段只能是 allow 白名单中的名字。
+// session 设置了 Model 时，字符串形式的值（如来自 URL 参数）会按 model 字段的类型转换。
+// 条件按给出的顺序生成 SQL，相同的过滤条件总是生成相同的 SQL
+func (f Filter) Apply(session *gorm.DB, allow Columns) *gorm.DB {
+	if f.Empty() {
+		return session
+	}
+	var s *schema.Schema
+	if model := session.Statement.Model; model != nil {
+		stmt := &gorm.Statement{DB: session}
+		if err := stmt.Parse(model); err == nil {
+			s = stmt.Schema
+		}
+	}
+	expr, err := f.build(allow, s)
+	if err != nil {
+		_ = session.AddError(err)
+		return session
+	}
+	if expr == nil {
+		return session
+	}
+	return session.Where(expr)
+}
+
+// Validate 校验字段和操作符，不生成 SQL
+func (f Filter) Validate(allow Columns) error {
+	_, err := f.build(allow, nil)
+	return err
+}
+
+func (f Filter) build(allow Columns, s *schema.Schema) (clause.Expression, error) {
+	groups := 0
+	for _, set := range []bool{f.Field != "", len(f.And) > 0, len(f.Or) > 0} {
+		if set {
+			groups++
+		}
+	}
+	if groups > 1 {
+		return nil, errors.New("db: filter must be one of condition, and, or")
+	}
+	switch {
+	case len(f.And) > 0:
+		exprs, err := buildAll(f.And, allow, s)
+		if err != nil || len(exprs) == 0 {
+			return nil, err
+		}
+		return clause.And(exprs...), nil
+	case len(f.Or) > 0:
+		exprs, err := buildAll(f.Or, allow, s)
+		if err != nil || len(exprs) == 0 {
+			return nil, err
+		}
+		return clause.Or(exprs...), nil
+	case f.Field == "":
+		return nil, nil
+	}
+
+	name, ok := allow[f.Field]
+	if !ok {
+		return nil, fmt.Errorf("db: field %q is not filterable", f.Field)
+	}
+	column := clause.Column{Name: name}
+	var field *schema.Field
+	if s != nil {
+		if field = s.LookUpField(name); field != nil {
+			column.Name = field.DBName
+		}
+	}
+	value := func(v any) (any, error) {
+		return convertValue(v, field)
+	}
+	switch f.Op {
+	case OpEq, OpNe, OpGt, OpGte, OpLt, OpLte:
+		v, err := value(f.Value)
+		if err != nil {
+			return nil, fmt.Errorf("db: filter %s: %w", f.Field, err)
+		}
+		if v == nil && f.Op != OpEq && f.Op != OpNe {
+			return nil, fmt.Errorf("db: filter %s: %s requires a value", f.Field, f.Op)
+		}
+		switch f.Op {
+		case OpEq:
+			return clause.Eq{Column: column, Value: v}, nil
+		case OpNe:
+			return clause.Neq{Column: column, Value: v}, nil
+		case OpGt:
+			return clause.Gt{Column: column, Value: v}, nil
+		case OpGte:
+			return clause.Gte{Column: column, Value: v}, nil
+		case OpLt:
+			return clause.Lt{Column: column, Value: v}, nil
+		default:
+			return clause.Lte{Column: column, Value: v}, nil
+		}
+	case OpIn, OpNotIn:
+		list, err := listValue(f.Value)
+		if err != nil {
+			return nil, fmt.Errorf("db: filter %s: %w", f.Field, err)
+		}
+		for i := range list {
+			if list[i], err = value(list[i]); err != nil {
+				return nil, fmt.Errorf("db: filter %s: %w", f.Field, err)
+			}
+		}
+		if len(list) == 0 {
+			if f.Op == OpIn {
+				return clause.Expr{SQL: "1 = 0"}, nil
+			}
+			return nil, nil
+		}
+		if f.Op == OpIn {
+			return clause.IN{Column: column, Values: list}, nil
+		}
+		return clause.Not(clause.IN{Column: column, Values: list}), nil
+	case OpLike, OpPrefix:
+		str, ok := f.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("db: filter %s: %s requires a string", f.Field, f.Op)
+		}
+		pattern := escapeLike(str) + "%"
+		if f.Op == OpLike {
+			pattern = "%" + pattern
+		}
+		return clause.Expr{SQL: "? LIKE ? ESCAPE '!'", Vars: []any{column, pattern}}, nil
+	case OpBetween:
+		list, err := listValue(f.Value)
+		if err != nil || len(list) != 2 {
+			return nil, fmt.Errorf("db: filter %s: between requires two values", f.Field)
+		}
+		low, err := value(list[0])
+		if err != nil {
+			return nil, fmt.Errorf("db: filter %s: %w", f.Field, err)
+		}
+		high, err := value(list[1])
+		if err != nil {
+			return nil, fmt.Errorf("db: filter %s: %w", f.Field, err)
+		}
+		return clause.Expr{SQL: "? BETWEEN ? AND ?", Vars: []any{column, low, high}}, nil
+	case OpIsNull:
+		null, err := boolValue(f.Value)
+		if err != nil {
+			return nil, fmt.Errorf("db: filter %s: %w", f.Field, err)
+		}
+		if null {
+			return clause.Eq{Column: column, Value: nil}, nil
+		}
+		return clause.Neq{Column: column, Value: nil}, nil
+	}
+	return nil, fmt.Errorf("db: filter %s: unknown operator %q", f.Field, f.Op)
+}
+
+func buildAll(filters []Filter, allow Columns, s *schema.Schema) ([]clause.Expression, error) {
+	var exprs []clause.Expression
+	for _, f := range filters {
+		expr, err := f.build(allow, s)
+		if err != nil {
+			return nil, err
+		}
+		if expr != nil {
+			exprs = append(exprs, expr)
+		}
+	}
+	return exprs, nil
+}
+
+// likeEscaper 使用 ! 作为转义符，反斜杠在 MySQL 字符串中需要再次转义，不便跨数据库
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// listValue 将切片或逗号分隔的字符串转为 []any
+func listValue(v any) ([]any, error) {
+	if s, ok := v.(string); ok {
+		if s == "" {
+			return nil, nil
+		}
+		var list []any
+		for _, item := range strings.Split(s, ",") {
+			list = append(list, strings.TrimSpace(item))
+		}
+		return list, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected a list, got %T", v)
+	}
+	list := make([]any, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list, nil
+}
+
+func boolValue(v any) (bool, error) {
+	switch b := v.(type) {
+	case bool:
+		return b, nil
+	case string:
+		return strconv.ParseBool(b)
+	case nil:
+		return true, nil
+	}
+	return false, fmt.Errorf("expected a boolean, got %T", v)
+}
+
+// convertValue 将字符串或 JSON 数字转为 model 字段的类型，未知字段原样返回
+func convertValue(v any, field *schema.Field) (any, error) {
+	if field == nil || v == nil {
+		return v, nil
+	}
+	t := field.FieldType
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.TypeOf(v) == t {
+		return v, nil
+	}
+	s, isString := v.(string)
+	n, isNumber := v.(float64)
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if isNumber && n == float64(int64(n)) {
+			return int64(n), nil
+		}
+		if isString {
+			return strconv.ParseInt(s, 10, 64)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if isNumber && n >= 0 && n == float64(uint64(n)) {
+			return uint64(n), nil
+		}
+		if isString {
+			return strconv.ParseUint(s, 10, 64)
+		}
+	case reflect.Float32, reflect.Float64:
+		if isNumber {
+			return n, nil
+		}
+		if isString {
+			return strconv.ParseFloat(s, 64)
+		}
+	case reflect.Bool:
+		return boolValue(v)
+	case reflect.String:
+		if isString {
+			return reflect.ValueOf(s).Convert(t).Interface(), nil
+		}
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) && isString {
+			return time.Parse(time.RFC3339Nano, s)
+		}
+	}
+	return v, nil
+}
+
+//================================================================================
+
+// filterParam URL 参数中以 JSON 表示的过滤条件，如 filter={"or":[...]}
+const filterParam = "filter"
+
+// queryKey 匹配 field[op] 形式的参数名
+var queryKey = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_.]*)\[([a-z_]+)\]$`)
+
+// ParseQuery 从 URL 参数解析过滤条件：age[gt]=18&status[in]=a,b&name=jo，不带操作符时为 eq。
+// 只解析 allow 中的字段，其他参数（如分页参数）被忽略；复杂的 AND/OR 条件可通过 filter 参数传 JSON。
+// 条件按参数名排序，保证生成的 SQL 稳定
+func ParseQuery(values url.Values, allow Columns) (Filter, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var filters []Filter
+	for _, key := range keys {
+		if key == filterParam {
+			continue
+		}
+		field, op := key, OpEq
+		if m := queryKey.FindStringSubmatch(key); m != nil {
+			field, op = m[1], m[2]
+		}
+		if _, ok := allow[field]; !ok {
+			continue
+		}
+		for _, value := range values[key] {
+			f := Filter{Field: field, Op: op, Value: value}
+			if err := f.Validate(allow); err != nil {
+				return Filter{}, err
+			}
+			filters = append(filters, f)
+		}
+	}
+	for _, raw := range values[filterParam] {
+		f, err := ParseJSON([]byte(raw), allow)
+		if err != nil {
+			return Filter{}, err
+		}
+		if !f.Empty() {
+			filters = append(filters, f)
+		}
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return And(filters...), nil
+}
+
+// ParseJSON 解析 JSON 形式的过滤条件并按 allow 校验
+func ParseJSON(data []byte, allow Columns) (Filter, error) {
+	var f Filter
+	if err := json.Unmarshal(data, &f); err != nil {
+		return Filter{}, fmt.Errorf("db: invalid filter: %w", err)
+	}
+	if err := f.Validate(allow); err != nil {
+		return Filter{}, err
+	}
+	return f, nil
+}
@@ -0,0 +1,161 @@
+package db
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+var filterColumns = Columns{"name": "name", "age": "age", "deleted": "deleted_at"}
+
+// filterSQL 返回过滤条件在 test_users 上生成的 SQL，参数已内联
+func filterSQL(t *testing.T, db *gorm.DB, f Filter) string {
+	t.Helper()
+	var err error
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		tx = f.Apply(tx.Model(&testUser{}), filterColumns)
+		err = tx.Error
+		return tx.Find(&[]testUser{})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, where, _ := strings.Cut(sql, " WHERE ")
+	return where
+}
+
+func TestFilterSQL(t *testing.T) {
+	db := newTestWrapper(t, &Config{}).db
+	cases := []struct {
+		name   string
+		filter Filter
+		want   string
+	}{
+		{"empty", Filter{}, "`test_users`.`deleted_at` IS NULL"},
+		{"eq", Eq("name", "jo"), "`name` = \"jo\" AND `test_users`.`deleted_at` IS NULL"},
+		{"nested", Or(And(Gte("age", 18), Lt("age", 30)), In("name", "a", "b")),
+			"((`age` >= 18 AND `age` < 30) OR `name` IN (\"a\",\"b\")) AND `test_users`.`deleted_at` IS NULL"},
+		{"not in", NotIn("age", 1, 2), "`age` NOT IN (1,2) AND `test_users`.`deleted_at` IS NULL"},
+		{"empty in", In("age"), "1 = 0 AND `test_users`.`deleted_at` IS NULL"},
+		{"like", Like("name", "50%_a!"), "`name` LIKE \"%50!%!_a!!%\" ESCAPE '!' AND `test_users`.`deleted_at` IS NULL"},
+		{"prefix", Prefix("name", "jo"), "`name` LIKE \"jo%\" ESCAPE '!' AND `test_users`.`deleted_at` IS NULL"},
+		{"between", Between("age", "18", "30"), "(`age` BETWEEN 18 AND 30) AND `test_users`.`deleted_at` IS NULL"},
+		{"is null", IsNull("deleted", false), "`deleted_at` IS NOT NULL AND `test_users`.`deleted_at` IS NULL"},
+	}
+	for _, c := range cases {
+		if got := filterSQL(t, db, c.filter); got != c.want {
+			t.Errorf("%s:\n got %s\nwant %s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	db := newTestWrapper(t, &Config{}).db
+	cases := []struct {
+		name   string
+		filter Filter
+		msg    string
+	}{
+		{"not filterable", Eq("version", 1), `db: field "version" is not filterable`},
+		{"nested not filterable", Or(Eq("name", "a"), And(Gt("password", "x"))), `db: field "password" is not filterable`},
+		{"unknown operator", Filter{Field: "age", Op: "regex", Value: "."}, `unknown operator "regex"`},
+		{"mixed group", Filter{Field: "age", Op: OpEq, Value: 1, Or: []Filter{Eq("name", "a")}}, "must be one of condition, and, or"},
+		{"bad number", Gt("age", "abc"), `db: filter age: strconv.ParseInt`},
+		{"missing value", Gt("age", nil), "gt requires a value"},
+		{"like number", Filter{Field: "name", Op: OpLike, Value: 1}, "like requires a string"},
+		{"between one value", Filter{Field: "age", Op: OpBetween, Value: "1"}, "between requires two values"},
+	}
+	for _, c := range cases {
+		err := c.filter.Apply(db.Model(&testUser{}), filterColumns).Find(&[]testUser{}).Error
+		if err == nil || !strings.Contains(err.Error(), c.msg) {
+			t.Errorf("%s: error = %v, want %q", c.name, err, c.msg)
+		}
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	db := newTestWrapper(t, &Config{}).db
+	values, err := url.ParseQuery(`name=jo&age[gte]=18&age[lt]=30&page=2&version=1&filter={"or":[{"field":"name","op":"prefix","value":"a"},{"field":"age","op":"in","value":[1,2]}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ParseQuery(values, filterColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 参数按名字排序，不在白名单中的参数被忽略，JSON 条件在最后
+	want := "(`age` >= 18 AND `age` < 30 AND `name` = \"jo\" AND (`name` LIKE \"a%\" ESCAPE '!' OR `age` IN (1,2))) AND `test_users`.`deleted_at` IS NULL"
+	for i := 0; i < 3; i++ {
+		if got := filterSQL(t, db, f); got != want {
+			t.Fatalf("got  %s\nwant %s", got, want)
+		}
+	}
+
+	f, err = ParseQuery(url.Values{"age[in]": {"1, 2"}}, filterColumns)
+	if err != nil || f.Op != OpIn {
+		t.Fatalf("single condition = %+v, %v", f, err)
+	}
+	if f, err = ParseQuery(url.Values{"page": {"1"}}, filterColumns); err != nil || !f.Empty() {
+		t.Errorf("no conditions = %+v, %v", f, err)
+	}
+	for _, query := range []string{
+		"age[regex]=1",
+		"name[between]=a",
+		`filter={"field":"version","op":"eq","value":1}`,
+		`filter={"or":`,
+	} {
+		values, _ := url.ParseQuery(query)
+		if _, err = ParseQuery(values, filterColumns); err == nil {
+			t.Errorf("ParseQuery(%s) should fail", query)
+		}
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	f, err := ParseJSON([]byte(`{"and":[{"field":"age","op":"gt","value":18},{"or":[{"field":"name","op":"eq","value":"a"},{"field":"deleted","op":"is_null","value":true}]}]}`), filterColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.And) != 2 || len(f.And[1].Or) != 2 || f.And[0].Value != 18.0 {
+		t.Errorf("parsed %+v", f)
+	}
+	if _, err = ParseJSON([]byte(`{"field":"password","op":"eq","value":"x"}`), filterColumns); err == nil {
+		t.Error("field outside the allowlist should fail")
+	}
+	if _, err = ParseJSON([]byte(`[1]`), filterColumns); err == nil || !strings.HasPrefix(err.Error(), "db: invalid filter") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestFilterQuery(t *testing.T) {
+	db := newTestWrapper(t, &Config{}).db
+	for _, name := range []string{"100%", "1000", "a_b", "axb", "x!y"} {
+		if err := db.Create(&testUser{Name: name, Age: len(name)}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	cases := []struct {
+		filter Filter
+		want   []string
+	}{
+		// % 和 _ 按普通字符匹配
+		{Like("name", "0%"), []string{"100%"}},
+		{Like("name", "_"), []string{"a_b"}},
+		{Prefix("name", "x!"), []string{"x!y"}},
+		// URL 参数的字符串按字段类型转换为数字
+		{And(Gt("age", "3"), Prefix("name", "1")), []string{"100%", "1000"}},
+		{In("age", "3", 4.0), []string{"100%", "1000", "a_b", "axb", "x!y"}},
+	}
+	for _, c := range cases {
+		var names []string
+		err := c.filter.Apply(db.Model(&testUser{}), filterColumns).Order("name").Pluck("name", &names).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(names, ",") != strings.Join(c.want, ",") {
+			t.Errorf("%+v matched %v, want %v", c.filter, names, c.want)
+		}
+	}
+}
@@ -2,12 +2,12 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"github.com/xybingbing/pkg/log"
-	"gorm.io/driver/mysql"
-	"gorm.io/driver/postgres"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+	"sort"
 	"sync"
 	"time"
 )
@@ -30,30 +30,110 @@ CREATE TABLE `example` (
 type Config struct {
 	Logger           *log.Logger
 	Type             string `default:"mysql"`
-	DSN              string `default:"-"`
+	DSN              string `default:"-" secret:"true"`
 	MaxIdleConn      int    `default:"10"`    // 最大空闲连接数，默认10
 	MaxOpenConn      int    `default:"100"`   // 最大活动连接数，默认100
 	ConnMaxLifetime  int    `default:"300"`   // 连接的最大存活时间，默认300s
 	ConnMaxIdleTime  int    `default:"300"`   // 连接的最大空闲时间，默认300s
-	SlowLogThreshold int    `default:"1000"`  // 慢日志阈值，默认1000ms
-	EnableDebug      bool   `default:"false"` // 是否开启调试
+	SlowLogThreshold int    `default:"1000"`  // 慢日志阈值，默认1000ms，0 为不记录慢日志
+	SlowLogExplain   bool   `default:"false"` // 慢查询为 SELECT 时是否同时记录执行计划
+	EnableDebug      bool   `default:"false"` // 是否开启调试，开启后以 info 级别记录所有 SQL
 	EnableMetric     bool   `default:"false"` // 是否开启监控
 	EnableTrace      bool   `default:"true"`  // 是否开启链路追踪，默认开启
-	dbName           string
-	interceptors     []Interceptor
+
+	Replicas             []string `secret:"true"`         // 只读副本的 DSN，配置后查询走副本，写入和事务走主库
+	ReplicaPolicy        string   `default:"round_robin"` // 副本负载均衡策略：round_robin、random、least_conn
+	ReplicaCheckInterval int      `default:"5"`           // 副本健康检查间隔，默认5s
+	StickyWindow         int      `default:"0"`           // 写入后同一 context（见 ReadYourWrites）读主库的时间，单位ms
+
+	RetryAttempts    int `default:"3"`  // 事务外的查询遇到临时错误时最多执行的次数，1 为不重试
+	RetryBackoff     int `default:"20"` // 首次重试前的等待时间，之后每次翻倍并加入随机抖动，默认20ms
+	BreakerThreshold int `default:"10"` // 连续多少次临时错误后熔断，0 为不熔断
+	BreakerTimeout   int `default:"10"` // 熔断后多久放行一个探测请求，默认10s
+
+	Cache    Cache // 查询缓存，为 nil 时不缓存，查询通过 WithCache 或 Cached 开启
+	CacheTTL int   `default:"60"` // 查询缓存的默认过期时间，默认60s
+
+	dbName       string
+	dialect      Dialect
+	resolver     *resolver
+	cache        *queryCache
+	breaker      *breaker
+	interceptors []Interceptor
 }
 
 var instances = sync.Map{}
 
 type Wrapper struct {
-	db *gorm.DB
+	name     string
+	db       *gorm.DB
+	dialect  Dialect
+	resolver *resolver
+	cache    *queryCache
+	breaker  *breaker
 }
 
 func (wrap *Wrapper) GetDB() *gorm.DB {
 	return wrap.db
 }
 
+// Dialect 返回当前数据库的方言
+func (wrap *Wrapper) Dialect() Dialect {
+	return wrap.dialect
+}
+
+// SetMaxIdleConn 运行时修改最大空闲连接数，可直接作为 conf.Value[int] 的 OnChange 回调
+func (wrap *Wrapper) SetMaxIdleConn(n int) {
+	wrap.eachDB(func(db *sql.DB) {
+		db.SetMaxIdleConns(n)
+	})
+}
+
+// SetMaxOpenConn 运行时修改最大活动连接数
+func (wrap *Wrapper) SetMaxOpenConn(n int) {
+	wrap.eachDB(func(db *sql.DB) {
+		db.SetMaxOpenConns(n)
+	})
+}
+
+// SetConnMaxLifetime 运行时修改连接的最大存活时间，单位秒
+func (wrap *Wrapper) SetConnMaxLifetime(seconds int) {
+	wrap.eachDB(func(db *sql.DB) {
+		db.SetConnMaxLifetime(time.Duration(seconds) * time.Second)
+	})
+}
+
+// SetConnMaxIdleTime 运行时修改连接的最大空闲时间，单位秒
+func (wrap *Wrapper) SetConnMaxIdleTime(seconds int) {
+	wrap.eachDB(func(db *sql.DB) {
+		db.SetConnMaxIdleTime(time.Duration(seconds) * time.Second)
+	})
+}
+
+// Close 停止副本健康检查，关闭主库和副本的连接池，并移除 NewWrapper 缓存的实例
+func (wrap *Wrapper) Close() error {
+	instances.CompareAndDelete(wrap.name, wrap)
+	err := wrap.resolver.close()
+	db, dbErr := wrap.db.DB()
+	if dbErr != nil {
+		return errors.Join(err, dbErr)
+	}
+	return errors.Join(err, db.Close())
+}
+
+// eachDB 对主库和所有副本执行 fn
+func (wrap *Wrapper) eachDB(fn func(db *sql.DB)) {
+	if db, err := wrap.db.DB(); err == nil {
+		fn(db)
+	}
+	wrap.resolver.each(fn)
+}
+
+// GetSession 创建使用 ctx 的新会话，ctx 中有 Wrapper.Transaction 开启的事务时加入该事务
 func GetSession(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{db: db}).(*gorm.DB); ok {
+		db = tx
+	}
 	return db.Session(&gorm.Session{NewDB: true, Context: ctx})
 }
 
@@ -71,47 +151,31 @@ func NewWrapper(config *Config, obj ...string) (*Wrapper, error) {
 		return nil, err
 	}
 	wrapper := &Wrapper{
-		db: gormDB,
+		name:     name,
+		db:       gormDB,
+		dialect:  config.dialect,
+		resolver: config.resolver,
+		cache:    config.cache,
+		breaker:  config.breaker,
 	}
 	instances.Store(name, wrapper)
 	return wrapper, nil
 }
 
 func newDB(config *Config) (*gorm.DB, error) {
-	var (
-		gormDB *gorm.DB
-		err    error
-	)
-
-	logger := NewZapLog(config.Logger.Logger)
-
-	switch config.Type {
-	case TypeSQLite:
-		gormDB, err = gorm.Open(sqlite.Open(config.DSN), &gorm.Config{
-			Logger: logger,
-		})
-		if err != nil {
-			return nil, err
-		}
-	case TypeMySQL:
-		gormDB, err = gorm.Open(mysql.Open(config.DSN), &gorm.Config{
-			Logger: logger,
-		})
-		if err != nil {
-			return nil, err
-		}
-	case TypePostgreSQL:
-		gormDB, err = gorm.Open(postgres.Open(config.DSN), &gorm.Config{
-			Logger: logger,
-		})
-		if err != nil {
-			return nil, err
-		}
-	}
+	logger := newLogger(config)
 
-	if gormDB == nil {
-		return nil, errors.New("gormDB is nil")
+	dialect, err := GetDialect(config.Type)
+	if err != nil {
+		return nil, err
+	}
+	gormDB, err := gorm.Open(dialect.Open(config.DSN), &gorm.Config{
+		Logger: logger,
+	})
+	if err != nil {
+		return nil, err
 	}
+	config.dialect = dialect
 
 	db, err := gormDB.DB()
 	if err != nil {
@@ -119,25 +183,31 @@ func newDB(config *Config) (*gorm.DB, error) {
 	}
 
 	// 设置默认连接配置
-	db.SetMaxIdleConns(config.MaxIdleConn)
-	db.SetMaxOpenConns(config.MaxOpenConn)
-	if config.ConnMaxLifetime != 0 {
-		db.SetConnMaxLifetime(time.Duration(config.ConnMaxLifetime) * time.Second)
-	}
-	if config.ConnMaxIdleTime != 0 {
-		db.SetConnMaxIdleTime(time.Duration(config.ConnMaxIdleTime) * time.Second)
-	}
+	setPool(db, config)
 	if err = db.Ping(); err != nil {
 		return nil, err
 	}
-	err = db.QueryRow("SELECT DATABASE()").Scan(&config.dbName)
+	config.dbName, err = dialect.CurrentDatabase(context.Background(), db)
 	if err != nil {
 		return nil, err
 	}
 	//拦截器
 	config.interceptors = make([]Interceptor, 0)
-	if config.EnableDebug {
-		config.interceptors = append(config.interceptors, debugInterceptor)
+	if config.SlowLogExplain && config.SlowLogThreshold > 0 {
+		// 在最内层，只统计数据库执行的耗时，重试时在实际执行的连接池上查询
+		config.interceptors = append(config.interceptors, explainInterceptor)
+	}
+	if config.BreakerThreshold > 0 {
+		config.breaker = newBreaker(config)
+	}
+	if config.breaker != nil || config.RetryAttempts > 1 {
+		// 在缓存之内，命中缓存的查询不受熔断影响
+		config.interceptors = append(config.interceptors, retryInterceptor)
+	}
+	if config.Cache != nil {
+		// 在重试之外、链路追踪和监控之内，命中缓存的查询同样有链路追踪和监控
+		config.cache = newQueryCache(config)
+		config.interceptors = append(config.interceptors, cacheInterceptor)
 	}
 	if config.EnableTrace {
 		config.interceptors = append(config.interceptors, traceInterceptor)
@@ -170,9 +240,37 @@ func newDB(config *Config) (*gorm.DB, error) {
 	if err = replace(gormDB.Callback().Raw(), "gorm:raw", config.interceptors...); err != nil {
 		return nil, err
 	}
+	// 回调替换完成后再开启，EnableDebug 时以 info 级别记录所有 SQL
+	if config.EnableDebug {
+		logger.LogLevel = gormLogger.Info
+	}
+	//读写分离
+	if len(config.Replicas) > 0 {
+		if config.resolver, err = newResolver(config, gormDB.ConnPool); err != nil {
+			return nil, err
+		}
+		if err = config.resolver.register(gormDB); err != nil {
+			return nil, err
+		}
+		if config.ReplicaCheckInterval > 0 {
+			go config.resolver.check(time.Duration(config.ReplicaCheckInterval) * time.Second)
+		}
+	}
 	return gormDB, nil
 }
 
+// setPool 按配置设置连接池
+func setPool(db *sql.DB, config *Config) {
+	db.SetMaxIdleConns(config.MaxIdleConn)
+	db.SetMaxOpenConns(config.MaxOpenConn)
+	if config.ConnMaxLifetime != 0 {
+		db.SetConnMaxLifetime(time.Duration(config.ConnMaxLifetime) * time.Second)
+	}
+	if config.ConnMaxIdleTime != 0 {
+		db.SetConnMaxIdleTime(time.Duration(config.ConnMaxIdleTime) * time.Second)
+	}
+}
+
 //================================================================================
 
 type Page struct {
@@ -181,7 +279,9 @@ type Page struct {
 	OrderBy  string `json:"order_by"`
 }
 
-func PageOption(session *gorm.DB, pg *Page) *gorm.DB {
+// PageOption 按页码分页，OrderBy 只接受 allow 白名单中的字段，未提供白名单时只接受普通的列名，
+// 不合法的 OrderBy 会作为 session 的错误返回。大表翻页请使用 Paginate
+func PageOption(session *gorm.DB, pg *Page, allow ...Columns) *gorm.DB {
 	if pg == nil {
 		return session
 	}
@@ -194,16 +294,34 @@ func PageOption(session *gorm.DB, pg *Page) *gorm.DB {
 	offset := (pg.Page - 1) * pg.PageSize
 	session = session.Offset(int(offset)).Limit(int(pg.PageSize))
 	if pg.OrderBy != "" {
-		session = session.Order(pg.OrderBy)
+		var columns Columns
+		if len(allow) > 0 {
+			columns = allow[0]
+		}
+		orders, err := ParseOrderBy(pg.OrderBy, columns)
+		if err != nil {
+			_ = session.AddError(err)
+			return session
+		}
+		session = session.Clauses(orderClause(orders))
 	}
 	return session
 }
 
+// Filters key 为 SQL 片段的条件，不能用于用户输入。
+//
+// Deprecated: 使用 Filter 和字段白名单
 type Filters map[string]interface{}
 
+// Execute 按 key 排序后添加条件，保证生成的 SQL 稳定
 func (list Filters) Execute(db *gorm.DB) *gorm.DB {
-	for key, val := range list {
-		db = db.Where(key, val)
+	keys := make([]string, 0, len(list))
+	for key := range list {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		db = db.Where(key, list[key])
 	}
 	return db
 }
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/metric"
-	"log"
 	"strings"
 	"time"
 
@@ -29,22 +28,6 @@ type Processor interface {
 // Interceptor ...
 type Interceptor func(string, *Config) func(next Handler) Handler
 
-// debug拦截器
-func debugInterceptor(callbackName string, config *Config) func(Handler) Handler {
-	return func(next Handler) Handler {
-		return func(db *gorm.DB) {
-			beg := time.Now()
-			next(db)
-			cost := time.Since(beg)
-			if db.Error != nil {
-				log.Println("[", cost, "]", "\x1b[32m", logSQL(db), "\x1b[0m", "err:", "\x1b[31m", db.Error.Error(), "\x1b[0m")
-			} else {
-				log.Println("[", cost, "]", "\x1b[32m", logSQL(db), "\x1b[0m")
-			}
-		}
-	}
-}
-
 // metric拦截器
 func metricInterceptor(callbackName string, config *Config) func(Handler) Handler {
 	return func(next Handler) Handler {
@@ -72,8 +55,10 @@ func traceInterceptor(callbackName string, config *Config) func(Handler) Handler
 				if len(db.Statement.BuildClauses) > 0 {
 					operation += strings.ToLower(db.Statement.BuildClauses[0])
 				}
-				_, span := otel.Tracer(callbackName).Start(db.Statement.Context, operation, nil)
+				ctx, span := otel.Tracer(callbackName).Start(db.Statement.Context, operation)
 				defer span.End()
+				// 日志中的 trace_id 与 SQL 注释中的一致
+				db.Statement.Context = ctx
 				comment := fmt.Sprintf("traceId=%s", span.SpanContext().TraceID().String())
 				if db.Statement.SQL.Len() > 0 {
 					sql := db.Statement.SQL.String()
@@ -97,7 +82,7 @@ func traceInterceptor(callbackName string, config *Config) func(Handler) Handler
 					attribute.Int64("db.rows_affected", db.RowsAffected),
 				)
 				var err = db.Error
-				if !errors.Is(err, gorm.ErrRecordNotFound) {
+				if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 					span.RecordError(db.Error)
 					span.SetStatus(codes.Error, db.Error.Error())
 					return
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"path/filepath"
@@ -9,7 +10,9 @@ import (
 	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
@@ -33,6 +36,95 @@ func NewZapLog(zapLogger *zap.Logger) logger.Interface {
 	}
 }
 
+// newLogger 按配置创建日志，超过 SlowLogThreshold 的查询记录为 warn
+func newLogger(config *Config) *Logger {
+	l := NewZapLog(config.Logger.Logger).(*Logger)
+	l.SlowThreshold = time.Duration(config.SlowLogThreshold) * time.Millisecond
+	return l
+}
+
+// explainKey 慢查询的执行计划保存在 Statement.Context 中的 key，由 explainInterceptor 写入，Trace 记录
+type explainKey struct{}
+
+type explainResult struct {
+	plan string
+	err  error
+}
+
+// explainInterceptor 查询超过 SlowLogThreshold 时查询执行计划。使用 Statement 中带占位符的 SQL 和参数，
+// 在执行查询的同一个连接池（副本或主库）上执行；事务中不查询
+func explainInterceptor(callbackName string, config *Config) func(Handler) Handler {
+	threshold := time.Duration(config.SlowLogThreshold) * time.Millisecond
+	return func(next Handler) Handler {
+		// gorm:row 返回时结果还未读取，连接仍被占用
+		if callbackName != "gorm:query" {
+			return next
+		}
+		return func(db *gorm.DB) {
+			begin := time.Now()
+			next(db)
+			if db.Error != nil || db.DryRun || time.Since(begin) <= threshold {
+				return
+			}
+			if _, inTx := db.Statement.ConnPool.(gorm.TxCommitter); inTx {
+				return
+			}
+			query := db.Statement.SQL.String()
+			if !strings.HasPrefix(strings.ToUpper(normalizeSQL(query)), "SELECT") {
+				return
+			}
+			ctx := db.Statement.Context
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			plan, err := explain(ctx, config.dialect, db.Statement.ConnPool, query, db.Statement.Vars)
+			db.Statement.Context = context.WithValue(ctx, explainKey{}, explainResult{plan: plan, err: err})
+		}
+	}
+}
+
+// explained 返回 explainInterceptor 保存的执行计划
+func explained(ctx context.Context) (explainResult, bool) {
+	if ctx == nil {
+		return explainResult{}, false
+	}
+	result, ok := ctx.Value(explainKey{}).(explainResult)
+	return result, ok
+}
+
+// explain 在 pool 上查询执行计划，每行结果的各列以制表符分隔
+func explain(ctx context.Context, dialect Dialect, pool gorm.ConnPool, query string, vars []any) (string, error) {
+	// 查询本身可能已超时，执行计划使用独立的超时时间
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+	defer cancel()
+	rows, err := pool.QueryContext(ctx, dialect.Explain(query), vars...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	values := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return "", err
+		}
+		fields := make([]string, len(values))
+		for i, value := range values {
+			fields[i] = value.String
+		}
+		lines = append(lines, strings.Join(fields, "\t"))
+	}
+	return strings.Join(lines, "\n"), rows.Err()
+}
+
 func (l *Logger) LogMode(level logger.LogLevel) logger.Interface {
 	newLogger := *l
 	newLogger.LogLevel = level
@@ -62,30 +154,60 @@ func (l Logger) Trace(ctx context.Context, begin time.Time, fc func() (string, i
 		return
 	}
 	elapsed := time.Since(begin)
-	elapsedStr := fmt.Sprintf("%.3fms", float64(elapsed.Nanoseconds())/1e6)
-	zapLogger := l.zapLogger()
-	if err != nil && (!errors.Is(err, logger.ErrRecordNotFound) || !l.IgnoreRecordNotFoundError) {
+	switch {
+	case err != nil && l.LogLevel >= logger.Error && (!errors.Is(err, logger.ErrRecordNotFound) || !l.IgnoreRecordNotFoundError):
 		sql, rows := fc()
-		if rows == -1 {
-			zapLogger.Error("trace", zap.Error(err), zap.String("elapsed", elapsedStr), zap.Int64("rows", rows), zap.String("sql", sql))
-		} else {
-			zapLogger.Error("trace", zap.Error(err), zap.String("elapsed", elapsedStr), zap.Int64("rows", rows), zap.String("sql", sql))
+		l.zapLogger().Error("trace", append(l.fields(ctx, elapsed, sql, rows), zap.Error(err))...)
+	case l.SlowThreshold != 0 && elapsed > l.SlowThreshold && l.LogLevel >= logger.Warn:
+		sql, rows := fc()
+		fields := append(l.fields(ctx, elapsed, sql, rows), zap.Duration("threshold", l.SlowThreshold))
+		if result, ok := explained(ctx); ok {
+			if result.err != nil {
+				fields = append(fields, zap.NamedError("explain_error", result.err))
+			} else {
+				fields = append(fields, zap.String("explain", result.plan))
+			}
+		}
+		l.zapLogger().Warn("slow sql", fields...)
+	case l.LogLevel >= logger.Info:
+		sql, rows := fc()
+		l.zapLogger().Info("trace", l.fields(ctx, elapsed, sql, rows)...)
+	}
+}
+
+// fields 查询日志的公共字段，ctx 中有链路追踪时附带 trace_id
+func (l Logger) fields(ctx context.Context, elapsed time.Duration, sql string, rows int64) []zap.Field {
+	fields := []zap.Field{
+		zap.String("elapsed", fmt.Sprintf("%.3fms", float64(elapsed.Nanoseconds())/1e6)),
+		zap.Int64("rows", rows),
+		zap.String("sql", sql),
+	}
+	if ctx != nil {
+		if span := trace.SpanContextFromContext(ctx); span.HasTraceID() {
+			fields = append(fields, zap.String("trace_id", span.TraceID().String()))
 		}
 	}
+	return fields
 }
 
 var (
 	gormPackage = filepath.Join("gorm.io", "gorm")
+	// dbPackage 本包的目录，拦截器等本包内的调用不作为日志的调用位置
+	dbPackage = func() string {
+		_, file, _, _ := runtime.Caller(0)
+		return filepath.Dir(file)
+	}()
 )
 
 func (l Logger) zapLogger() *zap.Logger {
 	zapLogger := l.ZapLogger
-	for i := 2; i < 15; i++ {
+	for i := 2; i < 20; i++ {
 		_, file, _, ok := runtime.Caller(i)
 		switch {
 		case !ok:
 		case strings.HasSuffix(file, "_test.go"):
 		case strings.Contains(file, gormPackage):
+		case filepath.Dir(file) == dbPackage:
 		default:
 			return zapLogger.WithOptions(zap.AddCallerSkip(i - 1))
 		}
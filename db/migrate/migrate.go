@@ -0,0 +1,208 @@
+// Package migrate 基于版本号的数据库结构迁移，迁移文件通过 embed.FS 打包进程序：
+//
+//	//go:embed migrations/*.sql
+//	var migrations embed.FS
+//
+//	m := migrate.New(wrapper)
+//	if err := m.AddFS(migrations, "migrations"); err != nil { ... }
+//	err := m.Up(ctx)
+//
+// 文件名格式为 <version>_<name>.up.sql 和 <version>_<name>.down.sql，down 文件可省略
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xybingbing/pkg/db"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrOutOfOrder 存在版本号小于已执行的最大版本、但尚未执行的迁移
+	ErrOutOfOrder = errors.New("migrate: out of order migration")
+	// ErrChecksum 已执行的迁移文件内容被修改
+	ErrChecksum = errors.New("migrate: checksum mismatch")
+	// ErrIrreversible 回滚的迁移没有 down
+	ErrIrreversible = errors.New("migrate: irreversible migration")
+)
+
+// Func Go 编写的迁移，tx 已在事务中并绑定持有迁移锁的连接，应通过 tx 执行所有语句，
+// 不要再通过 GetSession 或 Wrapper.Transaction 从连接池获取连接
+type Func func(ctx context.Context, tx *gorm.DB) error
+
+// Migration 单个版本的迁移
+type Migration struct {
+	Version  int64
+	Name     string
+	Checksum string // SQL 迁移 up 文件的 sha256，Go 迁移为空
+
+	upSQL, downSQL string
+	up, down       Func
+}
+
+// Reversible 是否可以回滚
+func (m *Migration) Reversible() bool {
+	return m.down != nil || m.downSQL != ""
+}
+
+type options struct {
+	table      string
+	dryRun     io.Writer
+	outOfOrder bool
+}
+
+// Option Migrator 的可选配置
+type Option func(o *options)
+
+// WithTable 设置记录迁移版本的表名，默认 schema_migrations
+func WithTable(name string) Option {
+	return func(o *options) {
+		o.table = name
+	}
+}
+
+// WithDryRun 只将要执行的迁移和 SQL 输出到 w，不修改数据库
+func WithDryRun(w io.Writer) Option {
+	return func(o *options) {
+		o.dryRun = w
+	}
+}
+
+// WithOutOfOrder 允许执行版本号小于已执行最大版本的迁移，默认返回 ErrOutOfOrder
+func WithOutOfOrder() Option {
+	return func(o *options) {
+		o.outOfOrder = true
+	}
+}
+
+// Migrator 迁移执行器
+type Migrator struct {
+	wrap       *db.Wrapper
+	opts       *options
+	migrations map[int64]*Migration
+}
+
+// New 创建迁移执行器
+func New(wrap *db.Wrapper, opts ...Option) *Migrator {
+	o := &options{table: "schema_migrations"}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Migrator{wrap: wrap, opts: o, migrations: map[int64]*Migration{}}
+}
+
+// AddFS 读取 fsys 中 dir 目录下的 SQL 迁移文件
+func (m *Migrator) AddFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		version, name, direction, err := parseFileName(entry.Name())
+		if err != nil {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		mig, err := m.get(version, name)
+		if err != nil {
+			return err
+		}
+		if direction == "up" {
+			if mig.upSQL != "" || mig.up != nil {
+				return fmt.Errorf("migrate: duplicate up migration for version %d", version)
+			}
+			mig.upSQL = string(data)
+			sum := sha256.Sum256(data)
+			mig.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			if mig.downSQL != "" || mig.down != nil {
+				return fmt.Errorf("migrate: duplicate down migration for version %d", version)
+			}
+			mig.downSQL = string(data)
+		}
+	}
+	for _, mig := range m.migrations {
+		if mig.upSQL == "" && mig.up == nil {
+			return fmt.Errorf("migrate: version %d has no up migration", mig.Version)
+		}
+	}
+	return nil
+}
+
+// AddGo 添加 Go 编写的迁移，down 为 nil 时不可回滚
+func (m *Migrator) AddGo(version int64, name string, up, down Func) error {
+	if up == nil {
+		return fmt.Errorf("migrate: version %d has no up migration", version)
+	}
+	mig, err := m.get(version, name)
+	if err != nil {
+		return err
+	}
+	if mig.upSQL != "" || mig.up != nil {
+		return fmt.Errorf("migrate: duplicate up migration for version %d", version)
+	}
+	mig.up, mig.down = up, down
+	return nil
+}
+
+// Migrations 返回按版本号排序的所有迁移
+func (m *Migrator) Migrations() []*Migration {
+	list := make([]*Migration, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		list = append(list, mig)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+	return list
+}
+
+// get 返回 version 对应的迁移，不存在时创建
+func (m *Migrator) get(version int64, name string) (*Migration, error) {
+	mig, ok := m.migrations[version]
+	if !ok {
+		mig = &Migration{Version: version, Name: name}
+		m.migrations[version] = mig
+		return mig, nil
+	}
+	if mig.Name != name {
+		return nil, fmt.Errorf("migrate: version %d used by both %q and %q", version, mig.Name, name)
+	}
+	return mig, nil
+}
+
+// parseFileName 解析 20240501120000_create_users.up.sql 形式的文件名
+func parseFileName(name string) (version int64, title, direction string, err error) {
+	base := strings.TrimSuffix(name, ".sql")
+	switch {
+	case strings.HasSuffix(base, ".up"):
+		direction = "up"
+	case strings.HasSuffix(base, ".down"):
+		direction = "down"
+	default:
+		return 0, "", "", fmt.Errorf("migrate: %s: expected .up.sql or .down.sql", name)
+	}
+	base = strings.TrimSuffix(base, "."+direction)
+	num, title, _ := strings.Cut(base, "_")
+	version, err = strconv.ParseInt(num, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, "", "", fmt.Errorf("migrate: %s: invalid version %q", name, num)
+	}
+	return version, title, direction, nil
+}
@@ -0,0 +1,253 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/xybingbing/pkg/db"
+	"github.com/xybingbing/pkg/log"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var testMigrations = fstest.MapFS{
+	"migrations/1_create_users.up.sql":    {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);")},
+	"migrations/1_create_users.down.sql":  {Data: []byte("DROP TABLE users;")},
+	"migrations/2_add_age.up.sql":         {Data: []byte("ALTER TABLE users ADD COLUMN age INTEGER;\n-- 多条语句\nCREATE INDEX idx_users_age ON users (age);")},
+	"migrations/2_add_age.down.sql":       {Data: []byte("DROP INDEX idx_users_age;\nALTER TABLE users DROP COLUMN age;")},
+	"migrations/4_create_orders.up.sql":   {Data: []byte("CREATE TABLE orders (id INTEGER PRIMARY KEY);")},
+	"migrations/4_create_orders.down.sql": {Data: []byte("DROP TABLE orders;")},
+	"migrations/README.md":                {Data: []byte("ignored")},
+}
+
+// newTestWrapper 使用独立的 SQLite 文件，连接池只有一个连接，迁移占用额外连接时会超时
+func newTestWrapper(t *testing.T) *db.Wrapper {
+	t.Helper()
+	wrap, err := db.NewWrapper(&db.Config{
+		Type:        db.TypeSQLite,
+		DSN:         filepath.Join(t.TempDir(), "test.db"),
+		Logger:      &log.Logger{Logger: zap.NewNop()},
+		MaxOpenConn: 1,
+	}, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = wrap.Close() })
+	return wrap
+}
+
+func newTestMigrator(t *testing.T, wrap *db.Wrapper, opts ...Option) *Migrator {
+	t.Helper()
+	m := New(wrap, opts...)
+	if err := m.AddFS(testMigrations, "migrations"); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func testContext(t *testing.T) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// appliedVersions 返回 Status 中已执行的版本
+func appliedVersions(t *testing.T, m *Migrator) []int64 {
+	t.Helper()
+	list, err := m.Status(testContext(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []int64
+	for _, s := range list {
+		if s.Applied {
+			versions = append(versions, s.Version)
+		}
+	}
+	return versions
+}
+
+func hasTable(wrap *db.Wrapper, name string) bool {
+	return wrap.GetDB().Migrator().HasTable(name)
+}
+
+func TestMigrator(t *testing.T) {
+	wrap := newTestWrapper(t)
+	m := newTestMigrator(t, wrap)
+	ctx := testContext(t)
+
+	if err := m.To(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := appliedVersions(t, m); len(got) != 2 || got[1] != 2 {
+		t.Fatalf("applied %v after To(2)", got)
+	}
+	if !wrap.GetDB().Migrator().HasColumn("users", "age") || hasTable(wrap, "orders") {
+		t.Error("To(2) applied the wrong migrations")
+	}
+
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := appliedVersions(t, m); len(got) != 3 || !hasTable(wrap, "orders") {
+		t.Fatalf("applied %v after Up", got)
+	}
+	// 重复执行没有变化
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Rollback(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := appliedVersions(t, m); len(got) != 2 || hasTable(wrap, "orders") {
+		t.Fatalf("applied %v after Rollback(1)", got)
+	}
+	if err := m.To(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := appliedVersions(t, m); len(got) != 0 || hasTable(wrap, "users") {
+		t.Fatalf("applied %v after To(0)", got)
+	}
+}
+
+func TestMigratorDryRun(t *testing.T) {
+	wrap := newTestWrapper(t)
+	var out bytes.Buffer
+	m := newTestMigrator(t, wrap, WithDryRun(&out))
+	if err := m.Up(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"-- up 1 create_users\nCREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);\n",
+		"CREATE INDEX idx_users_age ON users (age);\n",
+		"-- up 4 create_orders\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("dry run output missing %q:\n%s", want, out.String())
+		}
+	}
+	if hasTable(wrap, "users") || hasTable(wrap, "schema_migrations") {
+		t.Error("dry run modified the database")
+	}
+}
+
+func TestMigratorChecksum(t *testing.T) {
+	wrap := newTestWrapper(t)
+	ctx := testContext(t)
+	if err := newTestMigrator(t, wrap).Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	changed := fstest.MapFS{}
+	for name, file := range testMigrations {
+		changed[name] = file
+	}
+	changed["migrations/2_add_age.up.sql"] = &fstest.MapFile{Data: []byte("ALTER TABLE users ADD COLUMN age BIGINT;")}
+	m := New(wrap)
+	if err := m.AddFS(changed, "migrations"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(ctx); !errors.Is(err, ErrChecksum) {
+		t.Errorf("error = %v, want ErrChecksum", err)
+	}
+}
+
+func TestMigratorOutOfOrder(t *testing.T) {
+	wrap := newTestWrapper(t)
+	ctx := testContext(t)
+	m := newTestMigrator(t, wrap)
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 合并分支后出现了版本号更小的迁移
+	late := func(opts ...Option) *Migrator {
+		m := newTestMigrator(t, wrap, opts...)
+		if err := m.AddFS(fstest.MapFS{
+			"late/3_create_logs.up.sql": {Data: []byte("CREATE TABLE logs (id INTEGER PRIMARY KEY);")},
+		}, "late"); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	if err := late().Up(ctx); !errors.Is(err, ErrOutOfOrder) {
+		t.Fatalf("error = %v, want ErrOutOfOrder", err)
+	}
+	if hasTable(wrap, "logs") {
+		t.Fatal("out of order migration applied")
+	}
+	if err := late(WithOutOfOrder()).Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !hasTable(wrap, "logs") {
+		t.Error("WithOutOfOrder did not apply the migration")
+	}
+}
+
+func TestMigratorGo(t *testing.T) {
+	wrap := newTestWrapper(t)
+	ctx := testContext(t)
+	m := newTestMigrator(t, wrap, WithTable("versions"))
+	seed := func(ctx context.Context, tx *gorm.DB) error {
+		return tx.Exec("INSERT INTO users (id, name) VALUES (1, 'admin')").Error
+	}
+	unseed := func(ctx context.Context, tx *gorm.DB) error {
+		return tx.Exec("DELETE FROM users WHERE id = 1").Error
+	}
+	if err := m.AddGo(5, "seed_users", seed, unseed); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddGo(6, "fail", func(ctx context.Context, tx *gorm.DB) error {
+		if err := tx.Exec("INSERT INTO users (id, name) VALUES (2, 'rolled back')").Error; err != nil {
+			return err
+		}
+		return errors.New("boom")
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// 失败的迁移整体回滚，之前的迁移保留
+	if err := m.Up(ctx); err == nil || !strings.Contains(err.Error(), "migrate: up 6 (fail): boom") {
+		t.Fatalf("error = %v", err)
+	}
+	var names []string
+	if err := wrap.GetDB().Table("users").Order("id").Pluck("name", &names).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "admin" {
+		t.Errorf("users = %v", names)
+	}
+	if got := appliedVersions(t, m); len(got) != 4 || !hasTable(wrap, "versions") {
+		t.Fatalf("applied %v", got)
+	}
+
+	if err := m.Rollback(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	if err := wrap.GetDB().Table("users").Count(&count).Error; err != nil || count != 0 {
+		t.Errorf("users after rollback = %d, %v", count, err)
+	}
+
+	// 没有 down 的迁移不能回滚
+	if err := New(wrap).AddGo(1, "nil", nil, nil); err == nil {
+		t.Error("AddGo without up should fail")
+	}
+	m = New(wrap, WithTable("seed_versions"))
+	if err := m.AddGo(1, "irreversible", seed, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.To(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Rollback(ctx, 1); !errors.Is(err, ErrIrreversible) {
+		t.Errorf("error = %v, want ErrIrreversible", err)
+	}
+}
@@ -0,0 +1,311 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xybingbing/pkg/db"
+	"gorm.io/gorm"
+)
+
+// record 迁移记录表的一行
+type record struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255"`
+	Checksum  string    `gorm:"size:64"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// Status 单个版本的执行状态
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	Missing   bool // 数据库中已执行，但找不到对应的迁移
+}
+
+// Up 执行所有未执行的迁移
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, math.MaxInt64)
+}
+
+// To 迁移到指定版本：高于当前版本时执行中间的迁移，低于当前版本时依次回滚
+func (m *Migrator) To(ctx context.Context, target int64) error {
+	return m.locked(ctx, func(ctx context.Context, session *gorm.DB, applied map[int64]record) error {
+		var current int64
+		for version := range applied {
+			if version > current {
+				current = version
+			}
+		}
+		if target < current {
+			return m.down(ctx, session, applied, func(version int64) bool { return version > target })
+		}
+		for _, mig := range m.Migrations() {
+			if mig.Version > target {
+				break
+			}
+			if rec, ok := applied[mig.Version]; ok {
+				if mig.Checksum != "" && rec.Checksum != "" && rec.Checksum != mig.Checksum {
+					return fmt.Errorf("%w: version %d (%s)", ErrChecksum, mig.Version, mig.Name)
+				}
+				continue
+			}
+			if mig.Version < current && !m.opts.outOfOrder {
+				return fmt.Errorf("%w: version %d (%s) is older than applied version %d", ErrOutOfOrder, mig.Version, mig.Name, current)
+			}
+			if err := m.apply(ctx, session, mig, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Rollback 回滚最近执行的 steps 个迁移
+func (m *Migrator) Rollback(ctx context.Context, steps int) error {
+	return m.locked(ctx, func(ctx context.Context, session *gorm.DB, applied map[int64]record) error {
+		versions := make([]int64, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+		rollback := map[int64]bool{}
+		for i := 0; i < steps && i < len(versions); i++ {
+			rollback[versions[i]] = true
+		}
+		return m.down(ctx, session, applied, func(version int64) bool { return rollback[version] })
+	})
+}
+
+// Status 返回所有迁移的执行状态，按版本号排序
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(db.GetSession(db.ForcePrimary(ctx), m.wrap.GetDB()))
+	if err != nil {
+		return nil, err
+	}
+	var list []Status
+	for _, mig := range m.Migrations() {
+		rec, ok := applied[mig.Version]
+		list = append(list, Status{Version: mig.Version, Name: mig.Name, Applied: ok, AppliedAt: rec.AppliedAt})
+	}
+	for version, rec := range applied {
+		if _, ok := m.migrations[version]; !ok {
+			list = append(list, Status{Version: version, Name: rec.Name, Applied: true, AppliedAt: rec.AppliedAt, Missing: true})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// down 按版本号从大到小回滚满足 match 的已执行迁移
+func (m *Migrator) down(ctx context.Context, session *gorm.DB, applied map[int64]record, match func(version int64) bool) error {
+	versions := make([]int64, 0, len(applied))
+	for version := range applied {
+		if match(version) {
+			versions = append(versions, version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+	for _, version := range versions {
+		mig, ok := m.migrations[version]
+		if !ok {
+			return fmt.Errorf("migrate: applied version %d (%s) not found", version, applied[version].Name)
+		}
+		if !mig.Reversible() {
+			return fmt.Errorf("%w: version %d (%s)", ErrIrreversible, mig.Version, mig.Name)
+		}
+		if err := m.apply(ctx, session, mig, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply 在 session 上开启事务执行单个迁移并更新迁移记录。MySQL 的 DDL 会隐式提交，失败时可能需要手动处理
+func (m *Migrator) apply(ctx context.Context, session *gorm.DB, mig *Migration, up bool) error {
+	direction, sqlText, fn := "up", mig.upSQL, mig.up
+	if !up {
+		direction, sqlText, fn = "down", mig.downSQL, mig.down
+	}
+	if w := m.opts.dryRun; w != nil {
+		fmt.Fprintf(w, "-- %s %d %s\n", direction, mig.Version, mig.Name)
+		if fn != nil {
+			fmt.Fprintln(w, "-- (go migration)")
+		}
+		for _, stmt := range splitStatements(sqlText) {
+			fmt.Fprintf(w, "%s;\n", stmt)
+		}
+		return nil
+	}
+	err := session.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range splitStatements(sqlText) {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		if fn != nil {
+			if err := fn(ctx, tx); err != nil {
+				return err
+			}
+		}
+		if !up {
+			return tx.Table(m.opts.table).Where("version = ?", mig.Version).Delete(&record{}).Error
+		}
+		return tx.Table(m.opts.table).Create(&record{
+			Version:   mig.Version,
+			Name:      mig.Name,
+			Checksum:  mig.Checksum,
+			AppliedAt: time.Now().UTC(),
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migrate: %s %d (%s): %w", direction, mig.Version, mig.Name, err)
+	}
+	return nil
+}
+
+// locked 在数据库命名锁内执行 fn，保证多个实例同时启动时只有一个在迁移。
+// 加锁的连接同时用于读取记录和执行迁移（session 绑定该连接），连接池只有一个连接时也不会死锁
+func (m *Migrator) locked(ctx context.Context, fn func(ctx context.Context, session *gorm.DB, applied map[int64]record) error) error {
+	ctx = db.ForcePrimary(ctx)
+	if m.opts.dryRun != nil {
+		session := db.GetSession(ctx, m.wrap.GetDB())
+		applied, err := m.applied(session)
+		if err != nil {
+			return err
+		}
+		return fn(ctx, session, applied)
+	}
+	sqlDB, err := m.wrap.GetDB().DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	dialect := m.wrap.Dialect()
+	name := "migrate:" + m.opts.table
+	if err = dialect.Lock(ctx, conn, name); err != nil {
+		return fmt.Errorf("migrate: lock: %w", err)
+	}
+	defer dialect.Unlock(context.Background(), conn, name)
+
+	session := m.wrap.GetDB().Session(&gorm.Session{NewDB: true, Context: ctx})
+	session.Statement.ConnPool = conn
+	if err = session.Table(m.opts.table).AutoMigrate(&record{}); err != nil {
+		return err
+	}
+	applied, err := m.applied(session)
+	if err != nil {
+		return err
+	}
+	return fn(ctx, session, applied)
+}
+
+// applied 读取已执行的迁移，记录表不存在时视为空
+func (m *Migrator) applied(session *gorm.DB) (map[int64]record, error) {
+	applied := map[int64]record{}
+	if !session.Migrator().HasTable(m.opts.table) {
+		return applied, nil
+	}
+	var records []record
+	if err := session.Table(m.opts.table).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	for _, rec := range records {
+		applied[rec.Version] = rec
+	}
+	return applied, nil
+}
+
+// dollarTag Postgres 的 dollar quoting 开始标记，$1 这样的参数不是
+var dollarTag = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+
+// splitStatements 按分号拆分 SQL，忽略引号、dollar quoting 和注释中的分号
+func splitStatements(text string) []string {
+	var (
+		stmts []string
+		buf   strings.Builder
+		quote rune
+	)
+	flush := func() {
+		if stmt := strings.TrimSpace(buf.String()); stmt != "" && !onlyComments(stmt) {
+			stmts = append(stmts, stmt)
+		}
+		buf.Reset()
+	}
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			end := i
+			for end < len(runes) && runes[end] != '\n' {
+				end++
+			}
+			buf.WriteString(string(runes[i:end]))
+			i = end - 1
+			continue
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			end := strings.Index(string(runes[i:]), "*/")
+			if end < 0 {
+				buf.WriteString(string(runes[i:]))
+				i = len(runes)
+				continue
+			}
+			comment := string(runes[i:])[:end+2]
+			buf.WriteString(comment)
+			i += len([]rune(comment)) - 1
+			continue
+		case r == '$':
+			// Postgres 的 $$ ... $$、$body$ ... $body$ 函数体中的分号不拆分
+			tag := dollarTag.FindString(string(runes[i:]))
+			if tag == "" {
+				break
+			}
+			rest := string(runes[i+len([]rune(tag)):])
+			end := strings.Index(rest, tag)
+			if end < 0 {
+				buf.WriteString(string(runes[i:]))
+				i = len(runes)
+				continue
+			}
+			quoted := tag + rest[:end+len(tag)]
+			buf.WriteString(quoted)
+			i += len([]rune(quoted)) - 1
+			continue
+		case r == ';':
+			flush()
+			continue
+		}
+		buf.WriteRune(r)
+	}
+	flush()
+	return stmts
+}
+
+// onlyComments 语句是否只有注释
+func onlyComments(stmt string) bool {
+	for _, line := range strings.Split(stmt, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+package migrate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	cases := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{
+			name: "quotes and comments",
+			sql:  "INSERT INTO t VALUES ('a;b'); -- c;d\nSELECT \"x;y\" /* ; */ FROM t;",
+			want: []string{"INSERT INTO t VALUES ('a;b')", "-- c;d\nSELECT \"x;y\" /* ; */ FROM t"},
+		},
+		{
+			name: "dollar quoting",
+			sql: `CREATE FUNCTION f() RETURNS trigger AS $$
+BEGIN
+  NEW.updated_at = now();
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+CREATE FUNCTION g() RETURNS text AS $body$ SELECT 'a;$$;b' $body$ LANGUAGE sql;`,
+			want: []string{
+				"CREATE FUNCTION f() RETURNS trigger AS $$\nBEGIN\n  NEW.updated_at = now();\n  RETURN NEW;\nEND;\n$$ LANGUAGE plpgsql",
+				"CREATE FUNCTION g() RETURNS text AS $body$ SELECT 'a;$$;b' $body$ LANGUAGE sql",
+			},
+		},
+		{
+			name: "positional parameters",
+			sql:  "SELECT $1; SELECT $2",
+			want: []string{"SELECT $1", "SELECT $2"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := splitStatements(c.sql); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %q\nwant %q", got, c.want)
+			}
+		})
+	}
+}
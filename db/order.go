@@ -0,0 +1,93 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm/clause"
+)
+
+// Columns 允许排序的字段白名单，key 为 OrderBy 中使用的名字，value 为数据库列名
+type Columns map[string]string
+
+// AllowColumns 字段名与列名相同的白名单
+func AllowColumns(names ...string) Columns {
+	columns := make(Columns, len(names))
+	for _, name := range names {
+		columns[name] = name
+	}
+	return columns
+}
+
+// Order 单个排序字段
+type Order struct {
+	Column string // 数据库列名
+	Desc   bool
+}
+
+// identifier 未提供白名单时允许的列名，可带表名前缀
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// ParseOrderBy 解析 "created_at desc, id" 或 "-created_at,id" 形式的排序。
+// allow 为 nil 时只接受普通的列名，否则只接受白名单中的字段
+func ParseOrderBy(orderBy string, allow Columns) ([]Order, error) {
+	var orders []Order
+	for _, item := range strings.Split(orderBy, ",") {
+		fields := strings.Fields(item)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("db: invalid order by %q", item)
+		}
+		name, desc := fields[0], false
+		if rest, ok := strings.CutPrefix(name, "-"); ok {
+			name, desc = rest, true
+		}
+		if len(fields) == 2 {
+			switch strings.ToLower(fields[1]) {
+			case "asc":
+			case "desc":
+				desc = !desc
+			default:
+				return nil, fmt.Errorf("db: invalid order direction %q", fields[1])
+			}
+		}
+		column := name
+		if allow != nil {
+			var ok bool
+			if column, ok = allow[name]; !ok {
+				return nil, fmt.Errorf("db: column %q is not sortable", name)
+			}
+		} else if !identifier.MatchString(name) {
+			return nil, fmt.Errorf("db: invalid order column %q", name)
+		}
+		orders = append(orders, Order{Column: column, Desc: desc})
+	}
+	return orders, nil
+}
+
+// orderClause 生成列名经过转义的 ORDER BY 子句
+func orderClause(orders []Order) clause.OrderBy {
+	var orderBy clause.OrderBy
+	for _, order := range orders {
+		orderBy.Columns = append(orderBy.Columns, clause.OrderByColumn{
+			Column: clause.Column{Name: order.Column},
+			Desc:   order.Desc,
+		})
+	}
+	return orderBy
+}
+
+// orderString 排序的规范形式，用于校验游标是否属于同一个排序
+func orderString(orders []Order) string {
+	parts := make([]string, len(orders))
+	for i, order := range orders {
+		parts[i] = order.Column
+		if order.Desc {
+			parts[i] = "-" + order.Column
+		}
+	}
+	return strings.Join(parts, ",")
+}
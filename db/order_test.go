@@ -0,0 +1,40 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOrderBy(t *testing.T) {
+	allow := Columns{"created": "created_at", "id": "id"}
+	cases := []struct {
+		orderBy string
+		allow   Columns
+		want    []Order
+		err     string
+	}{
+		{"created desc, id", allow, []Order{{Column: "created_at", Desc: true}, {Column: "id"}}, ""},
+		{"-created,id ASC", allow, []Order{{Column: "created_at", Desc: true}, {Column: "id"}}, ""},
+		{"-created desc", allow, []Order{{Column: "created_at"}}, ""},
+		{" , ", allow, nil, ""},
+		{"users.name", nil, []Order{{Column: "users.name"}}, ""},
+		{"password", allow, nil, `db: column "password" is not sortable`},
+		{"created_at", allow, nil, `db: column "created_at" is not sortable`},
+		{"id up", allow, nil, `db: invalid order direction "up"`},
+		{"id desc nulls", allow, nil, `db: invalid order by "id desc nulls"`},
+		{"name;drop", nil, nil, `db: invalid order column "name;drop"`},
+		{"(select)", nil, nil, `db: invalid order column "(select)"`},
+	}
+	for _, c := range cases {
+		got, err := ParseOrderBy(c.orderBy, c.allow)
+		if c.err != "" {
+			if err == nil || err.Error() != c.err {
+				t.Errorf("ParseOrderBy(%q) error = %v, want %s", c.orderBy, err, c.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ParseOrderBy(%q) = %v, %v, want %v", c.orderBy, got, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrVersionConflict 乐观锁冲突，记录已被其他请求修改
+var ErrVersionConflict = errors.New("db: version conflict")
+
+var deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+
+// Hooks Repository 写操作前后的回调，与写操作在同一个事务中，返回错误会回滚
+type Hooks[T any] struct {
+	BeforeCreate func(ctx context.Context, item *T) error
+	AfterCreate  func(ctx context.Context, item *T) error
+	BeforeUpdate func(ctx context.Context, item *T) error
+	AfterUpdate  func(ctx context.Context, item *T) error
+	BeforeDelete func(ctx context.Context, id any) error
+	AfterDelete  func(ctx context.Context, id any) error
+}
+
+// RepositoryOption Repository 的可选配置
+type RepositoryOption[T any] func(r *Repository[T])
+
+// WithFilterColumns 设置 Find、List 允许过滤的字段，默认不允许任何字段，避免客户端按敏感列过滤
+func WithFilterColumns[T any](columns Columns) RepositoryOption[T] {
+	return func(r *Repository[T]) {
+		r.filterable = columns
+	}
+}
+
+// WithSortColumns 设置 List 允许排序的字段，默认不允许任何字段，避免客户端按无索引的列排序
+func WithSortColumns[T any](columns Columns) RepositoryOption[T] {
+	return func(r *Repository[T]) {
+		r.sortable = columns
+	}
+}
+
+// WithVersionColumn 设置乐观锁的版本列，默认使用 version 列（存在时），传空字符串关闭
+func WithVersionColumn[T any](column string) RepositoryOption[T] {
+	return func(r *Repository[T]) {
+		r.versionColumn = &column
+	}
+}
+
+// WithBatchSize 设置 CreateBatch 每批插入的数量，默认 500
+func WithBatchSize[T any](size int) RepositoryOption[T] {
+	return func(r *Repository[T]) {
+		r.batchSize = size
+	}
+}
+
+// WithHooks 设置写操作的回调
+func WithHooks[T any](hooks Hooks[T]) RepositoryOption[T] {
+	return func(r *Repository[T]) {
+		r.hooks = hooks
+	}
+}
+
+// Repository 单个 model 的通用增删改查，所有方法通过 GetSession 使用 ctx，会自动加入 ctx 中的事务
+type Repository[T any] struct {
+	wrap          *Wrapper
+	schema        *schema.Schema
+	primary       *schema.Field
+	version       *schema.Field
+	deletedAt     *schema.Field
+	filterable    Columns
+	sortable      Columns
+	versionColumn *string
+	batchSize     int
+	hooks         Hooks[T]
+	unscoped      bool
+}
+
+// NewRepository 创建 model T 的 Repository，T 必须有主键
+func NewRepository[T any](wrap *Wrapper, opts ...RepositoryOption[T]) (*Repository[T], error) {
+	r := &Repository[T]{wrap: wrap, batchSize: 500}
+	for _, opt := range opts {
+		opt(r)
+	}
+	stmt := &gorm.Statement{DB: wrap.db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	r.schema = stmt.Schema
+	if r.primary = r.schema.PrioritizedPrimaryField; r.primary == nil {
+		return nil, fmt.Errorf("db: %s has no primary key", r.schema.Name)
+	}
+	for _, f := range r.schema.Fields {
+		if f.FieldType == deletedAtType {
+			r.deletedAt = f
+		}
+	}
+	if r.filterable == nil {
+		r.filterable = Columns{}
+	}
+	if r.sortable == nil {
+		r.sortable = Columns{}
+	}
+	versionColumn := "version"
+	if r.versionColumn != nil {
+		versionColumn = *r.versionColumn
+	}
+	if versionColumn != "" {
+		r.version = r.schema.LookUpField(versionColumn)
+		if r.version == nil && r.versionColumn != nil {
+			return nil, fmt.Errorf("db: %s has no column %q", r.schema.Name, versionColumn)
+		}
+	}
+	if r.version != nil {
+		switch r.version.FieldType.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			return nil, fmt.Errorf("db: version column %q must be an integer", r.version.DBName)
+		}
+	}
+	return r, nil
+}
+
+// WithDeleted 返回包含已软删除记录的 Repository
+func (r *Repository[T]) WithDeleted() *Repository[T] {
+	clone := *r
+	clone.unscoped = true
+	return &clone
+}
+
+// base 带有 ctx 的会话
+func (r *Repository[T]) base(ctx context.Context) *gorm.DB {
+	session := GetSession(ctx, r.wrap.db)
+	if r.unscoped {
+		session = session.Unscoped()
+	}
+	return session
+}
+
+// session 以 T 为 model 的会话
+func (r *Repository[T]) session(ctx context.Context) *gorm.DB {
+	return r.base(ctx).Model(new(T))
+}
+
+func (r *Repository[T]) byID(id any) clause.Expression {
+	return clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: r.primary.DBName}, Value: id}
+}
+
+// Get 按主键查询，不存在时返回 gorm.ErrRecordNotFound
+func (r *Repository[T]) Get(ctx context.Context, id any) (*T, error) {
+	item := new(T)
+	if err := r.session(ctx).Where(r.byID(id)).Take(item).Error; err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// First 返回满足条件的第一条记录（按主键排序），不存在时返回 gorm.ErrRecordNotFound
+func (r *Repository[T]) First(ctx context.Context, filter Filter) (*T, error) {
+	item := new(T)
+	if err := filter.Apply(r.session(ctx), r.filterable).First(item).Error; err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Find 返回满足条件的所有记录
+func (r *Repository[T]) Find(ctx context.Context, filter Filter) ([]T, error) {
+	var items []T
+	err := filter.Apply(r.session(ctx), r.filterable).Find(&items).Error
+	return items, err
+}
+
+// Count 统计满足条件的记录数
+func (r *Repository[T]) Count(ctx context.Context, filter Filter) (int64, error) {
+	var total int64
+	err := filter.Apply(r.session(ctx), r.filterable).Count(&total).Error
+	return total, err
+}
+
+// List 按页码分页查询，同时返回满足条件的总数
+func (r *Repository[T]) List(ctx context.Context, filter Filter, pg *Page) ([]T, int64, error) {
+	session := filter.Apply(r.session(ctx), r.filterable)
+	total, err := r.countSession(session)
+	if err != nil {
+		return nil, 0, err
+	}
+	var items []T
+	err = PageOption(session, pg, r.sortable).Find(&items).Error
+	return items, total, err
+}
+
+// Paginate 游标分页查询，p.Columns 为空时使用 WithSortColumns 的设置
+func (r *Repository[T]) Paginate(ctx context.Context, filter Filter, p *Paginator, c *Cursor) (*PageResult[T], error) {
+	if p.Columns == nil {
+		clone := *p
+		clone.Columns = r.sortable
+		p = &clone
+	}
+	session := filter.Apply(r.session(ctx), r.filterable)
+	if session.Error != nil {
+		return nil, session.Error
+	}
+	return Paginate[T](session, p, c)
+}
+
+func (r *Repository[T]) countSession(session *gorm.DB) (int64, error) {
+	var total int64
+	err := session.Session(&gorm.Session{}).Count(&total).Error
+	return total, err
+}
+
+// Create 插入一条记录
+func (r *Repository[T]) Create(ctx context.Context, item *T) error {
+	return r.write(ctx, item, r.hooks.BeforeCreate, r.hooks.AfterCreate, func(session *gorm.DB) error {
+		return session.Create(item).Error
+	})
+}
+
+// CreateBatch 按 WithBatchSize 分批插入，所有批次在同一个事务中，不调用 Hooks
+func (r *Repository[T]) CreateBatch(ctx context.Context, items []T) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return r.wrap.Transaction(ctx, func(ctx context.Context) error {
+		return r.session(ctx).CreateInBatches(items, r.batchSize).Error
+	})
+}
+
+// Upsert 插入记录，conflict 列冲突时更新 updates 列，updates 为空时忽略冲突
+func (r *Repository[T]) Upsert(ctx context.Context, item *T, conflict []string, updates []string) error {
+	return r.write(ctx, item, r.hooks.BeforeCreate, r.hooks.AfterCreate, func(session *gorm.DB) error {
+		return session.Clauses(r.wrap.dialect.Upsert(conflict, updates)).Create(item).Error
+	})
+}
+
+// Update 按主键更新记录，columns 为空时更新所有字段（包括零值）。
+// 有版本列时只在版本未变化时更新，并将版本加一，否则返回 ErrVersionConflict；记录不存在时返回 gorm.ErrRecordNotFound
+func (r *Repository[T]) Update(ctx context.Context, item *T, columns ...string) error {
+	return r.write(ctx, item, r.hooks.BeforeUpdate, r.hooks.AfterUpdate, func(base *gorm.DB) error {
+		rv := reflect.ValueOf(item)
+		id, zero := r.primary.ValueOf(ctx, rv)
+		if zero {
+			return fmt.Errorf("db: update %s without primary key", r.schema.Name)
+		}
+		session := base.Model(item)
+		if len(columns) == 0 {
+			session = session.Select("*").Omit(r.primary.DBName)
+		} else {
+			session = session.Select(columns)
+		}
+		if r.version == nil {
+			// MySQL 的 RowsAffected 不包含值没有变化的行，为 0 时需要确认记录是否存在
+			result := session.Updates(item)
+			if result.Error == nil && result.RowsAffected == 0 {
+				return r.mustExist(base, id)
+			}
+			return result.Error
+		}
+		current, _ := r.version.ValueOf(ctx, rv)
+		next := reflect.ValueOf(current).Convert(r.version.FieldType)
+		if next.CanInt() {
+			next = reflect.ValueOf(next.Int() + 1).Convert(r.version.FieldType)
+		} else {
+			next = reflect.ValueOf(next.Uint() + 1).Convert(r.version.FieldType)
+		}
+		if err := r.version.Set(ctx, rv, next.Interface()); err != nil {
+			return err
+		}
+		if len(columns) > 0 {
+			session = session.Select(append(append([]string{}, columns...), r.version.DBName))
+		}
+		result := session.Where(clause.Eq{Column: clause.Column{Name: r.version.DBName}, Value: current}).Updates(item)
+		if result.Error != nil || result.RowsAffected == 0 {
+			_ = r.version.Set(ctx, rv, current)
+		}
+		if result.Error == nil && result.RowsAffected == 0 {
+			if err := r.mustExist(base, id); err != nil {
+				return err
+			}
+			return ErrVersionConflict
+		}
+		return result.Error
+	})
+}
+
+// mustExist 主键对应的记录不存在时返回 gorm.ErrRecordNotFound
+func (r *Repository[T]) mustExist(session *gorm.DB, id any) error {
+	var count int64
+	if err := session.Model(new(T)).Where(r.byID(id)).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete 按主键删除，model 有 gorm.DeletedAt 字段时为软删除
+func (r *Repository[T]) Delete(ctx context.Context, id any) error {
+	return r.remove(ctx, id, r.session)
+}
+
+// HardDelete 按主键物理删除，忽略软删除
+func (r *Repository[T]) HardDelete(ctx context.Context, id any) error {
+	return r.remove(ctx, id, func(ctx context.Context) *gorm.DB {
+		return r.session(ctx).Unscoped()
+	})
+}
+
+// Restore 恢复软删除的记录
+func (r *Repository[T]) Restore(ctx context.Context, id any) error {
+	if r.deletedAt == nil {
+		return fmt.Errorf("db: %s does not support soft delete", r.schema.Name)
+	}
+	result := r.session(ctx).Unscoped().Where(r.byID(id)).Update(r.deletedAt.DBName, nil)
+	if result.Error == nil && result.RowsAffected == 0 {
+		// 记录未被删除时 MySQL 的 RowsAffected 为 0，需要在主库确认记录是否存在
+		return r.mustExist(r.session(ForcePrimary(ctx)).Unscoped(), id)
+	}
+	return result.Error
+}
+
+func (r *Repository[T]) remove(ctx context.Context, id any, session func(ctx context.Context) *gorm.DB) error {
+	return r.wrap.Transaction(ctx, func(ctx context.Context) error {
+		if r.hooks.BeforeDelete != nil {
+			if err := r.hooks.BeforeDelete(ctx, id); err != nil {
+				return err
+			}
+		}
+		result := session(ctx).Where(r.byID(id)).Delete(new(T))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if r.hooks.AfterDelete != nil {
+			return r.hooks.AfterDelete(ctx, id)
+		}
+		return nil
+	})
+}
+
+// write 在事务中执行写操作及其前后的回调
+func (r *Repository[T]) write(ctx context.Context, item *T, before, after func(ctx context.Context, item *T) error, fn func(session *gorm.DB) error) error {
+	return r.wrap.Transaction(ctx, func(ctx context.Context) error {
+		if before != nil {
+			if err := before(ctx, item); err != nil {
+				return err
+			}
+		}
+		if err := fn(r.base(ctx)); err != nil {
+			return err
+		}
+		if after != nil {
+			return after(ctx, item)
+		}
+		return nil
+	})
+}
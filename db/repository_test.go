@@ -0,0 +1,232 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/xybingbing/pkg/log"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type testUser struct {
+	ID        uint64 `gorm:"primaryKey"`
+	Name      string
+	Age       int
+	Version   int
+	DeletedAt gorm.DeletedAt
+}
+
+// newTestWrapper 每个测试使用独立的 SQLite 文件，实例按测试名缓存
+func newTestWrapper(t *testing.T, config *Config) *Wrapper {
+	t.Helper()
+	config.Type = TypeSQLite
+	config.DSN = filepath.Join(t.TempDir(), "test.db")
+	config.Logger = &log.Logger{Logger: zap.NewNop()}
+	config.MaxOpenConn = 1
+	wrap, err := NewWrapper(config, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = wrap.Close() })
+	// 配置了副本时迁移中的查询也会被分发到副本
+	if err = wrap.db.WithContext(ForcePrimary(context.Background())).AutoMigrate(&testUser{}); err != nil {
+		t.Fatal(err)
+	}
+	return wrap
+}
+
+func newTestRepository(t *testing.T, opts ...RepositoryOption[testUser]) *Repository[testUser] {
+	t.Helper()
+	repo, err := NewRepository[testUser](newTestWrapper(t, &Config{}), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestRepositoryCRUD(t *testing.T) {
+	repo := newTestRepository(t, WithFilterColumns[testUser](Columns{"name": "name"}))
+	ctx := context.Background()
+
+	user := &testUser{Name: "alice", Age: 20}
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if user.ID == 0 {
+		t.Fatal("id not set after create")
+	}
+	got, err := repo.Get(ctx, user.ID)
+	if err != nil || got.Name != "alice" {
+		t.Fatalf("get = %+v, %v", got, err)
+	}
+
+	user.Age = 21
+	if err = repo.Update(ctx, user, "age"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ = repo.Get(ctx, user.ID); got.Age != 21 || got.Version != 1 {
+		t.Errorf("after update age = %d version = %d, want 21 1", got.Age, got.Version)
+	}
+	// 值没有变化的更新不是冲突
+	if err = repo.Update(ctx, user, "age"); err != nil {
+		t.Errorf("no-op update: %v", err)
+	}
+
+	if items, err := repo.Find(ctx, Eq("name", "alice")); err != nil || len(items) != 1 {
+		t.Errorf("find = %v, %v", items, err)
+	}
+	if _, err = repo.Find(ctx, Eq("age", 21)); err == nil {
+		t.Error("find by a column outside the allowlist should fail")
+	}
+
+	if err = repo.HardDelete(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = repo.Get(ctx, user.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("get after delete: %v", err)
+	}
+	if err = repo.Update(ctx, user); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("update after delete: %v", err)
+	}
+}
+
+func TestRepositoryUpdateWithoutVersion(t *testing.T) {
+	repo := newTestRepository(t, WithVersionColumn[testUser](""))
+	ctx := context.Background()
+
+	user := &testUser{Name: "alice"}
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := repo.Update(ctx, user, "name"); err != nil {
+			t.Fatalf("update %d: %v", i, err)
+		}
+	}
+	if err := repo.Update(ctx, &testUser{ID: user.ID + 1, Name: "bob"}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("update missing row: %v", err)
+	}
+}
+
+func TestRepositoryCreateBatch(t *testing.T) {
+	repo := newTestRepository(t, WithBatchSize[testUser](2))
+	ctx := context.Background()
+
+	users := []testUser{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}, {Name: "e"}}
+	if err := repo.CreateBatch(ctx, users); err != nil {
+		t.Fatal(err)
+	}
+	if total, err := repo.Count(ctx, Filter{}); err != nil || total != 5 {
+		t.Errorf("count = %d, %v, want 5", total, err)
+	}
+	for _, user := range users {
+		if user.ID == 0 {
+			t.Errorf("%s has no id", user.Name)
+		}
+	}
+}
+
+func TestRepositorySoftDelete(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	user := &testUser{Name: "alice"}
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Get(ctx, user.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("get after soft delete: %v", err)
+	}
+	if got, err := repo.WithDeleted().Get(ctx, user.ID); err != nil || !got.DeletedAt.Valid {
+		t.Errorf("get with deleted = %+v, %v", got, err)
+	}
+	if err := repo.Delete(ctx, user.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("delete twice: %v", err)
+	}
+
+	if err := repo.Restore(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := repo.Get(ctx, user.ID); err != nil || got.DeletedAt.Valid {
+		t.Errorf("get after restore = %+v, %v", got, err)
+	}
+	// 恢复未删除的记录不报错，记录不存在时返回 ErrRecordNotFound。
+	// 模拟 MySQL：值没有变化的行不计入 RowsAffected
+	err := repo.wrap.db.Callback().Update().After("gorm:update").Register("test:changed_rows", func(tx *gorm.DB) {
+		tx.RowsAffected = 0
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Restore(ctx, user.ID); err != nil {
+		t.Errorf("restore twice: %v", err)
+	}
+	if err := repo.Restore(ctx, user.ID+1); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("restore missing record: %v", err)
+	}
+}
+
+func TestRepositoryVersionConflict(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	user := &testUser{Name: "alice"}
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	stale := *user
+	user.Name = "bob"
+	if err := repo.Update(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	stale.Name = "carol"
+	if err := repo.Update(ctx, &stale); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("stale update: %v, want ErrVersionConflict", err)
+	}
+	if stale.Version != 0 {
+		t.Errorf("version after conflict = %d, want 0", stale.Version)
+	}
+	if got, _ := repo.Get(ctx, user.ID); got.Name != "bob" || got.Version != 1 {
+		t.Errorf("got %s version %d, want bob 1", got.Name, got.Version)
+	}
+}
+
+func TestRepositoryHookRollback(t *testing.T) {
+	failed := errors.New("hook failed")
+	repo := newTestRepository(t, WithHooks(Hooks[testUser]{
+		AfterCreate: func(ctx context.Context, item *testUser) error {
+			if item.Name == "bad" {
+				return failed
+			}
+			return nil
+		},
+		AfterDelete: func(ctx context.Context, id any) error {
+			return failed
+		},
+	}))
+	ctx := context.Background()
+
+	if err := repo.Create(ctx, &testUser{Name: "bad"}); !errors.Is(err, failed) {
+		t.Fatalf("create: %v", err)
+	}
+	if total, _ := repo.WithDeleted().Count(ctx, Filter{}); total != 0 {
+		t.Errorf("create not rolled back, count = %d", total)
+	}
+
+	user := &testUser{Name: "good"}
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(ctx, user.ID); !errors.Is(err, failed) {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := repo.Get(ctx, user.ID); err != nil {
+		t.Errorf("delete not rolled back: %v", err)
+	}
+}
@@ -0,0 +1,271 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 副本的负载均衡策略
+const (
+	PolicyRoundRobin = "round_robin"
+	PolicyRandom     = "random"
+	PolicyLeastConn  = "least_conn"
+)
+
+type forcePrimaryKey struct{}
+
+type stickyKey struct{}
+
+// ForcePrimary 返回强制读主库的 context，用于刚写入后必须读到最新数据的场景
+func ForcePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forcePrimaryKey{}, true)
+}
+
+// ReadYourWrites 返回记录写入时间的 context，通常在请求入口调用一次。
+// 使用该 context 写入后 StickyWindow 时间内的读请求都会走主库
+func ReadYourWrites(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(stickyKey{}).(*atomic.Int64); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, stickyKey{}, new(atomic.Int64))
+}
+
+// replica 只读副本
+type replica struct {
+	name    string
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// resolver 将读请求分发到副本，写请求和事务使用主库
+type resolver struct {
+	primary  gorm.ConnPool
+	replicas []*replica
+	policy   string
+	sticky   time.Duration
+	next     atomic.Uint64
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newResolver(config *Config, primary gorm.ConnPool) (*resolver, error) {
+	switch config.ReplicaPolicy {
+	case "":
+		config.ReplicaPolicy = PolicyRoundRobin
+	case PolicyRoundRobin, PolicyRandom, PolicyLeastConn:
+	default:
+		return nil, fmt.Errorf("db: unknown replica policy %q", config.ReplicaPolicy)
+	}
+	r := &resolver{
+		primary: primary,
+		policy:  config.ReplicaPolicy,
+		sticky:  time.Duration(config.StickyWindow) * time.Millisecond,
+		stop:    make(chan struct{}),
+	}
+	for i, dsn := range config.Replicas {
+		// 不在打开时连接，启动时不可用的副本先摘除，由健康检查恢复
+		gormDB, err := gorm.Open(openLazy(config.dialect, dsn), &gorm.Config{Logger: newLogger(config), DisableAutomaticPing: true})
+		if err != nil {
+			return nil, fmt.Errorf("db: open replica %d: %w", i, err)
+		}
+		db, err := gormDB.DB()
+		if err != nil {
+			return nil, err
+		}
+		setPool(db, config)
+		rep := &replica{name: fmt.Sprintf("replica-%d", i), db: db}
+		if err = db.Ping(); err != nil {
+			log.Printf("db: %s ejected: %v", rep.name, err)
+		}
+		rep.healthy.Store(err == nil)
+		r.replicas = append(r.replicas, rep)
+	}
+	return r, nil
+}
+
+// register 在查询前切换连接，写入前切回主库，写入后记录写入时间
+func (r *resolver) register(db *gorm.DB) error {
+	if err := db.Callback().Query().Before("gorm:query").Register("db:resolver", r.route); err != nil {
+		return err
+	}
+	if err := db.Callback().Row().Before("gorm:row").Register("db:resolver", r.route); err != nil {
+		return err
+	}
+	// 在开启事务之前，避免在副本上开启写事务
+	if err := db.Callback().Create().Before("gorm:begin_transaction").Register("db:primary", r.usePrimary); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:begin_transaction").Register("db:primary", r.usePrimary); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().Before("gorm:begin_transaction").Register("db:primary", r.usePrimary); err != nil {
+		return err
+	}
+	if err := db.Callback().Raw().Before("gorm:raw").Register("db:primary", r.usePrimary); err != nil {
+		return err
+	}
+	if err := db.Callback().Create().After("gorm:create").Register("db:sticky", r.markWrite); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Register("db:sticky", r.markWrite); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().After("gorm:delete").Register("db:sticky", r.markWrite); err != nil {
+		return err
+	}
+	return db.Callback().Raw().After("gorm:raw").Register("db:sticky", r.markWrite)
+}
+
+func (r *resolver) route(db *gorm.DB) {
+	// 事务中的 ConnPool 为 *sql.Tx，与主库不同
+	if db.Error != nil || db.Statement.ConnPool != r.primary {
+		return
+	}
+	// SELECT ... FOR UPDATE 需要在主库上加锁
+	if _, ok := db.Statement.Clauses["FOR"]; ok {
+		return
+	}
+	ctx := db.Statement.Context
+	if ctx != nil {
+		if force, _ := ctx.Value(forcePrimaryKey{}).(bool); force {
+			return
+		}
+		if last, ok := ctx.Value(stickyKey{}).(*atomic.Int64); ok && r.sticky > 0 &&
+			time.Since(time.Unix(0, last.Load())) < r.sticky {
+			return
+		}
+	}
+	if rep := r.pick(nil); rep != nil {
+		db.Statement.ConnPool = rep.db
+	}
+}
+
+// usePrimary 写入始终使用主库。查询钩子（如 AfterFind）中的写入与查询共用 Statement，
+// 会继承 route 切换到的副本
+func (r *resolver) usePrimary(db *gorm.DB) {
+	if r.replicaOf(db.Statement.ConnPool) != nil {
+		db.Statement.ConnPool = r.primary
+	}
+}
+
+// replicaOf 返回 pool 对应的副本，不是副本时返回 nil
+func (r *resolver) replicaOf(pool gorm.ConnPool) *replica {
+	if r == nil {
+		return nil
+	}
+	for _, rep := range r.replicas {
+		if pool == rep.db {
+			return rep
+		}
+	}
+	return nil
+}
+
+// reroute 查询在副本 failed 上失败后换一个健康的副本重试，没有其他可用副本时回落到主库
+func (r *resolver) reroute(db *gorm.DB, failed *replica) {
+	if rep := r.pick(failed); rep != nil {
+		db.Statement.ConnPool = rep.db
+	} else {
+		db.Statement.ConnPool = r.primary
+	}
+}
+
+func (r *resolver) markWrite(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Context == nil {
+		return
+	}
+	if last, ok := db.Statement.Context.Value(stickyKey{}).(*atomic.Int64); ok {
+		last.Store(time.Now().UnixNano())
+	}
+}
+
+// pick 按策略选择 exclude 以外健康的副本，没有可用副本时返回 nil，查询回落到主库
+func (r *resolver) pick(exclude *replica) *replica {
+	healthy := make([]*replica, 0, len(r.replicas))
+	for _, rep := range r.replicas {
+		if rep != exclude && rep.healthy.Load() {
+			healthy = append(healthy, rep)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	switch r.policy {
+	case PolicyRandom:
+		return healthy[rand.Intn(len(healthy))]
+	case PolicyLeastConn:
+		best := healthy[0]
+		for _, rep := range healthy[1:] {
+			if rep.db.Stats().InUse < best.db.Stats().InUse {
+				best = rep
+			}
+		}
+		return best
+	default:
+		return healthy[int(r.next.Add(1)-1)%len(healthy)]
+	}
+}
+
+// check 定期检查副本，直到 close
+func (r *resolver) check(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.probe(interval)
+		}
+	}
+}
+
+// probe 检查所有副本，失败的副本被摘除，恢复后重新加入
+func (r *resolver) probe(timeout time.Duration) {
+	for _, rep := range r.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := rep.db.PingContext(ctx)
+		cancel()
+		if was := rep.healthy.Swap(err == nil); was != (err == nil) {
+			if err != nil {
+				log.Printf("db: %s ejected: %v", rep.name, err)
+			} else {
+				log.Printf("db: %s recovered", rep.name)
+			}
+		}
+	}
+}
+
+// close 停止健康检查并关闭副本的连接池
+func (r *resolver) close() error {
+	if r == nil {
+		return nil
+	}
+	var errs []error
+	r.stopOnce.Do(func() {
+		close(r.stop)
+		for _, rep := range r.replicas {
+			errs = append(errs, rep.db.Close())
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// each 对所有副本执行 fn
+func (r *resolver) each(fn func(db *sql.DB)) {
+	if r == nil {
+		return
+	}
+	for _, rep := range r.replicas {
+		fn(rep.db)
+	}
+}
@@ -0,0 +1,258 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// seedReplica 创建副本数据库文件，其中 id 为 1 的记录名为 name
+func seedReplica(t *testing.T, file, name string) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(file), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	defer sqlDB.Close()
+	if err = db.AutoMigrate(&testUser{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Create(&testUser{ID: 1, Name: name}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newReplicaWrapper 主库和每个副本是不同的 SQLite 文件，id 为 1 的记录名分别为 primary、replica-N，
+// 通过记录名判断查询走了哪个库
+func newReplicaWrapper(t *testing.T, config *Config, replicas ...string) *Wrapper {
+	t.Helper()
+	config.Replicas = replicas
+	wrap := newTestWrapper(t, config)
+	if err := wrap.db.Create(&testUser{ID: 1, Name: "primary"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return wrap
+}
+
+// readName 读取 id 为 1 的记录名
+func readName(t *testing.T, db *gorm.DB) string {
+	t.Helper()
+	var user testUser
+	if err := db.First(&user, 1).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user.Name
+}
+
+func replicaFiles(t *testing.T, n int) []string {
+	t.Helper()
+	dir := t.TempDir()
+	files := make([]string, n)
+	for i := range files {
+		name := "replica-" + strconv.Itoa(i)
+		files[i] = filepath.Join(dir, name+".db")
+		seedReplica(t, files[i], name)
+	}
+	return files
+}
+
+func TestResolverRouting(t *testing.T) {
+	wrap := newReplicaWrapper(t, &Config{}, replicaFiles(t, 1)...)
+	ctx := context.Background()
+	db := wrap.db.WithContext(ctx)
+
+	cases := []struct {
+		name string
+		db   *gorm.DB
+		want string
+	}{
+		{"query", db, "replica-0"},
+		{"force primary", wrap.db.WithContext(ForcePrimary(ctx)), "primary"},
+		{"for update", db.Clauses(clause.Locking{Strength: "UPDATE"}), "primary"},
+	}
+	for _, c := range cases {
+		if got := readName(t, c.db); got != c.want {
+			t.Errorf("%s read from %s, want %s", c.name, got, c.want)
+		}
+	}
+
+	var name string
+	if err := db.Raw("SELECT name FROM test_users WHERE id = 1").Scan(&name).Error; err != nil || name != "replica-0" {
+		t.Errorf("raw query read from %s, %v", name, err)
+	}
+	err := wrap.Transaction(ctx, func(ctx context.Context) error {
+		name = readName(t, GetSession(ctx, wrap.db))
+		return nil
+	})
+	if err != nil || name != "primary" {
+		t.Errorf("transaction read from %s, %v", name, err)
+	}
+
+	// 写入始终在主库
+	if err = db.Model(&testUser{ID: 1}).Update("age", 30).Error; err != nil {
+		t.Fatal(err)
+	}
+	var user testUser
+	if err = wrap.db.WithContext(ForcePrimary(ctx)).First(&user, 1).Error; err != nil || user.Age != 30 {
+		t.Errorf("primary age = %d, %v", user.Age, err)
+	}
+}
+
+func TestResolverPolicies(t *testing.T) {
+	cases := []struct {
+		policy string
+		check  func(t *testing.T, seen []string)
+	}{
+		{PolicyRoundRobin, func(t *testing.T, seen []string) {
+			for i := 1; i < len(seen); i++ {
+				if seen[i] == seen[i-1] {
+					t.Errorf("round robin read %v", seen)
+					return
+				}
+			}
+		}},
+		{PolicyRandom, nil},
+		{PolicyLeastConn, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.policy, func(t *testing.T) {
+			wrap := newReplicaWrapper(t, &Config{ReplicaPolicy: c.policy}, replicaFiles(t, 2)...)
+			seen := make([]string, 6)
+			for i := range seen {
+				seen[i] = readName(t, wrap.db)
+				if seen[i] != "replica-0" && seen[i] != "replica-1" {
+					t.Fatalf("read from %s", seen[i])
+				}
+			}
+			if c.check != nil {
+				c.check(t, seen)
+			}
+		})
+	}
+
+	if _, err := newResolver(&Config{ReplicaPolicy: "fastest"}, nil); err == nil {
+		t.Error("unknown policy should fail")
+	}
+}
+
+func TestResolverHealthCheck(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "replica")
+	file := filepath.Join(dir, "replica.db")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	seedReplica(t, file, "replica-0")
+	wrap := newReplicaWrapper(t, &Config{}, file)
+	if got := readName(t, wrap.db); got != "replica-0" {
+		t.Fatalf("read from %s", got)
+	}
+
+	// 副本所在目录被删除后无法建立新连接（MaxIdleConn 为 0，不保留空闲连接），被摘除后查询回落到主库
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	wrap.resolver.probe(time.Second)
+	if got := readName(t, wrap.db); got != "primary" {
+		t.Errorf("read from %s after the replica was ejected", got)
+	}
+
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	seedReplica(t, file, "replica-0")
+	wrap.resolver.probe(time.Second)
+	if got := readName(t, wrap.db); got != "replica-0" {
+		t.Errorf("read from %s after the replica recovered", got)
+	}
+}
+
+func TestResolverStickyWindow(t *testing.T) {
+	wrap := newReplicaWrapper(t, &Config{StickyWindow: 60000}, replicaFiles(t, 1)...)
+	ctx := ReadYourWrites(context.Background())
+	if got := readName(t, wrap.db.WithContext(ctx)); got != "replica-0" {
+		t.Errorf("read before write from %s", got)
+	}
+	if err := wrap.db.WithContext(ctx).Create(&testUser{Name: "new"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := readName(t, wrap.db.WithContext(ctx)); got != "primary" {
+		t.Errorf("read after write from %s, want primary", got)
+	}
+	if got := readName(t, wrap.db.WithContext(ReadYourWrites(context.Background()))); got != "replica-0" {
+		t.Errorf("read in another context from %s", got)
+	}
+}
+
+// auditedUser 查询后在钩子中写入，写入与查询共用 Statement
+type auditedUser struct {
+	ID   uint64
+	Name string
+	Age  int
+}
+
+func (auditedUser) TableName() string {
+	return "test_users"
+}
+
+func (u *auditedUser) AfterFind(tx *gorm.DB) error {
+	return tx.Model(u).Update("age", gorm.Expr("age + 1")).Error
+}
+
+func TestResolverWriteAfterRead(t *testing.T) {
+	files := replicaFiles(t, 1)
+	wrap := newReplicaWrapper(t, &Config{}, files...)
+	var user auditedUser
+	if err := wrap.db.First(&user, 1).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "replica-0" {
+		t.Fatalf("read from %s", user.Name)
+	}
+
+	var primary testUser
+	if err := wrap.db.WithContext(ForcePrimary(context.Background())).First(&primary, 1).Error; err != nil || primary.Age != 1 {
+		t.Errorf("primary age = %d, %v, want 1", primary.Age, err)
+	}
+	replica, err := gorm.Open(sqlite.Open(files[0]), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := replica.DB()
+	defer sqlDB.Close()
+	var onReplica testUser
+	if err = replica.First(&onReplica, 1).Error; err != nil || onReplica.Age != 0 {
+		t.Errorf("replica age = %d, %v, want 0", onReplica.Age, err)
+	}
+}
+
+func TestWrapperClose(t *testing.T) {
+	config := &Config{ReplicaCheckInterval: 1}
+	wrap := newReplicaWrapper(t, config, replicaFiles(t, 1)...)
+	if err := wrap.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := instances.Load(t.Name()); ok {
+		t.Error("closed wrapper is still cached")
+	}
+	select {
+	case <-wrap.resolver.stop:
+	default:
+		t.Error("health check not stopped")
+	}
+	if err := wrap.db.Exec("SELECT 1").Error; err == nil {
+		t.Error("primary pool still open")
+	}
+	if err := wrap.resolver.replicas[0].db.Ping(); err == nil {
+		t.Error("replica pool still open")
+	}
+	// 重复关闭不会 panic
+	_ = wrap.Close()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 	"log"
 	"net/http"
@@ -14,6 +15,13 @@ func init() {
 	http.HandleFunc("/debug/db/stats", func(w http.ResponseWriter, r *http.Request) {
 		_ = json.NewEncoder(w).Encode(stats())
 	})
+	http.HandleFunc("/debug/db/health", func(w http.ResponseWriter, r *http.Request) {
+		report, ok := health(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(report)
+	})
 	go monitor()
 }
 
@@ -73,6 +81,14 @@ func monitor() {
 				observer.Observe(float64(sqlDB.Stats().WaitDuration.Milliseconds() / 1000))
 				return nil
 			}))
+			if wrap.breaker != nil {
+				// 0 关闭，1 半开，2 打开
+				_, _ = meter.Int64ObservableGauge("grom_breaker_state", metric.WithDescription("熔断器状态"), metric.WithInt64Callback(func(ctx context.Context, observer metric.Int64Observer) error {
+					state := map[string]int64{BreakerClosed: 0, BreakerHalfOpen: 1, BreakerOpen: 2}[wrap.BreakerState()]
+					observer.Observe(state, metric.WithAttributes(attribute.String("name", name)))
+					return nil
+				}))
+			}
 			return true
 		})
 		time.Sleep(30 * time.Second)
@@ -95,3 +111,19 @@ func stats() (stats map[string]interface{}) {
 	})
 	return
 }
+
+// health 所有实例的健康状态
+func health(ctx context.Context) (report map[string]interface{}, ok bool) {
+	report = make(map[string]interface{})
+	ok = true
+	instances.Range(func(key, val interface{}) bool {
+		wrap := val.(*Wrapper)
+		status := "ok"
+		if err := wrap.Health(ctx); err != nil {
+			status, ok = err.Error(), false
+		}
+		report[key.(string)] = map[string]string{"status": status, "breaker": wrap.BreakerState()}
+		return true
+	})
+	return
+}
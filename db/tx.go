@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"math/rand"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// txKey context 中保存事务的 key，按 Wrapper 区分，不同数据库的事务互不干扰
+type txKey struct {
+	db *gorm.DB
+}
+
+type txOptions struct {
+	sql.TxOptions
+	attempts int
+	backoff  time.Duration
+}
+
+// TxOption Transaction 的可选配置
+type TxOption func(o *txOptions)
+
+// WithIsolation 设置事务隔离级别
+func WithIsolation(level sql.IsolationLevel) TxOption {
+	return func(o *txOptions) {
+		o.Isolation = level
+	}
+}
+
+// WithReadOnly 开启只读事务
+func WithReadOnly() TxOption {
+	return func(o *txOptions) {
+		o.ReadOnly = true
+	}
+}
+
+// WithRetry 设置死锁、序列化失败时最多执行的次数和首次重试前的等待时间，之后每次翻倍。
+// attempts 为 1 时不重试，默认执行 3 次，等待 10ms
+func WithRetry(attempts int, backoff time.Duration) TxOption {
+	return func(o *txOptions) {
+		o.attempts = attempts
+		o.backoff = backoff
+	}
+}
+
+// Transaction 在事务中执行 fn，事务保存在传给 fn 的 ctx 中，GetSession(ctx, wrap.GetDB()) 会自动加入该事务。
+// 在已有事务的 ctx 中调用时使用 savepoint，fn 出错只回滚到 savepoint，此时 opts 不生效。
+// 最外层事务遇到死锁、序列化失败时会重新执行整个 fn，因此 fn 不应有事务之外的副作用
+func (wrap *Wrapper) Transaction(ctx context.Context, fn func(ctx context.Context) error, opts ...TxOption) error {
+	key := txKey{db: wrap.db}
+	if tx, ok := ctx.Value(key).(*gorm.DB); ok {
+		return tx.WithContext(ctx).Transaction(func(nested *gorm.DB) error {
+			return fn(context.WithValue(ctx, key, nested))
+		})
+	}
+	o := &txOptions{attempts: 3, backoff: 10 * time.Millisecond}
+	for _, opt := range opts {
+		opt(o)
+	}
+	var dirty *dirtyTables
+	if wrap.cache != nil {
+		dirty = &dirtyTables{}
+		ctx = context.WithValue(ctx, dirtyKey{}, dirty)
+	}
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = wrap.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return fn(context.WithValue(ctx, key, tx))
+		}, &o.TxOptions)
+		if err == nil && dirty != nil {
+			// 提交后再使写入过的表的查询缓存失效
+			if err := wrap.cache.invalidate(ctx, dirty.list()...); err != nil {
+				log.Printf("db: cache invalidate: %v", err)
+			}
+		}
+		if err == nil || attempt >= o.attempts || !wrap.dialect.Retryable(err) {
+			return err
+		}
+		if !sleep(ctx, backoff(o.backoff, attempt)) {
+			return err
+		}
+	}
+}
+
+// InTransaction ctx 中是否有 db 的事务
+func InTransaction(ctx context.Context, db *gorm.DB) bool {
+	_, ok := ctx.Value(txKey{db: db}).(*gorm.DB)
+	return ok
+}
+
+// maxBackoff 退避时间的上限（不含抖动），重试次数很多时避免移位溢出
+const maxBackoff = 5 * time.Second
+
+// backoff 第 attempt 次失败后的等待时间：指数退避并加入随机抖动，避免冲突的请求同时重试
+func backoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	wait := maxBackoff
+	if shift := attempt - 1; shift < 32 && base < maxBackoff>>shift {
+		wait = base << shift
+	}
+	return wait + time.Duration(rand.Int63n(int64(wait)+1))
+}
+
+// sleep 等待 d，ctx 结束时返回 false
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
@@ -0,0 +1,304 @@
+// Package flags 特性开关，开关定义放在由 conf 加载的配置中，支持布尔/多变体开关、
+// 按用户 key 稳定哈希的百分比灰度、基于上下文属性的定向规则以及配置热更新
+package flags
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+const (
+	VariantOn  = "on"
+	VariantOff = "off"
+)
+
+// 决策原因
+const (
+	ReasonNotFound = "not_found"
+	ReasonDisabled = "disabled"
+	ReasonRule     = "rule"
+	ReasonRollout  = "rollout"
+	ReasonDefault  = "default"
+)
+
+// Config 特性开关配置，通常作为业务配置的一个字段，例如 Flags flags.Config `mapstructure:"flags"`。
+// viper 会将 key 转为小写，因此开关名和变体名不区分大小写，Decision 中的变体统一为小写
+type Config map[string]Flag
+
+// Flag 单个开关的定义
+type Flag struct {
+	Enabled        bool           // 总开关，关闭时返回 OffVariant
+	Variants       map[string]any // 变体及其值，为空时为布尔开关：on=true，off=false
+	DefaultVariant string         // 未命中规则和灰度时的变体，默认 on
+	OffVariant     string         // 关闭时的变体，默认 off
+	Rules          []Rule         // 定向规则，按顺序匹配，命中第一条即返回
+	Rollout        []Rollout      // 百分比灰度，按用户 key 稳定哈希分桶
+}
+
+// Rule 定向规则，Attribute 为上下文属性名（key 表示用户 key），Operator 支持
+// eq、ne、in、not_in、prefix、suffix、contains
+type Rule struct {
+	Attribute string
+	Operator  string
+	Values    []string
+	Variant   string    // 命中后返回的变体
+	Rollout   []Rollout // 命中后在规则内按百分比分配，设置后忽略 Variant
+}
+
+// Rollout 变体所占的百分比，多个 Rollout 依次累加
+type Rollout struct {
+	Variant string
+	Percent float64
+}
+
+// Target 被评估的对象，Key 用于稳定哈希（通常为用户 id）
+type Target struct {
+	Key        string
+	Attributes map[string]string
+}
+
+type targetKey struct{}
+
+// NewContext 将评估对象放入 context
+func NewContext(ctx context.Context, target Target) context.Context {
+	return context.WithValue(ctx, targetKey{}, target)
+}
+
+// FromContext 从 context 中取出评估对象
+func FromContext(ctx context.Context) (Target, bool) {
+	target, ok := ctx.Value(targetKey{}).(Target)
+	return target, ok
+}
+
+// Decision 一次评估的结果
+type Decision struct {
+	Flag    string
+	Variant string
+	Value   any
+	Reason  string
+}
+
+// Client 开关评估客户端，配置可以随时通过 Update 或 Watch 热更新
+type Client struct {
+	flags   atomic.Pointer[Config]
+	logger  *zap.Logger
+	counter metric.Int64Counter
+}
+
+type Option func(c *Client)
+
+// WithLogger 记录每次评估决策的日志（debug 级别），使用 log 包时传入 Logger.Logger
+func WithLogger(logger *zap.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+func New(cfg Config, opts ...Option) *Client {
+	c := &Client{
+		logger: zap.NewNop(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.counter, _ = otel.Meter("flags").Int64Counter("feature_flag_evaluations", metric.WithDescription("特性开关评估次数"))
+	c.Update(cfg)
+	return c
+}
+
+// Update 替换全部开关定义
+func (c *Client) Update(cfg Config) {
+	normalized := make(Config, len(cfg))
+	for name, flag := range cfg {
+		normalized[strings.ToLower(name)] = flag.normalize()
+	}
+	c.flags.Store(&normalized)
+}
+
+// Watcher 可以订阅变化的配置，*conf.Watcher 满足该接口
+type Watcher interface {
+	Value() any
+	OnChange(fn func(v any))
+}
+
+// Watch 在配置重新加载后更新开关定义，get 从新的配置结构体中取出开关配置
+func (c *Client) Watch(w Watcher, get func(v any) Config) {
+	c.Update(get(w.Value()))
+	w.OnChange(func(v any) {
+		c.Update(get(v))
+	})
+}
+
+// Enabled 布尔开关是否打开，评估对象从 ctx 中获取
+func (c *Client) Enabled(ctx context.Context, name string) bool {
+	d := c.Evaluate(ctx, name)
+	if b, ok := d.Value.(bool); ok {
+		return b
+	}
+	return d.Variant == VariantOn
+}
+
+// Variant 返回多变体开关命中的变体名
+func (c *Client) Variant(ctx context.Context, name string) string {
+	return c.Evaluate(ctx, name).Variant
+}
+
+// Evaluate 评估开关并记录日志和指标
+func (c *Client) Evaluate(ctx context.Context, name string) Decision {
+	target, _ := FromContext(ctx)
+	d := c.evaluate(name, target)
+	c.logger.Debug("feature flag",
+		zap.String("flag", d.Flag),
+		zap.String("variant", d.Variant),
+		zap.String("reason", d.Reason),
+		zap.String("key", target.Key),
+	)
+	if c.counter != nil {
+		c.counter.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("flag", d.Flag),
+			attribute.String("variant", d.Variant),
+			attribute.String("reason", d.Reason),
+		))
+	}
+	return d
+}
+
+func (c *Client) evaluate(name string, target Target) Decision {
+	flag, ok := (*c.flags.Load())[strings.ToLower(name)]
+	if !ok {
+		return Decision{Flag: name, Reason: ReasonNotFound}
+	}
+	decide := func(variant, reason string) Decision {
+		return Decision{Flag: name, Variant: variant, Value: flag.value(variant), Reason: reason}
+	}
+	if !flag.Enabled {
+		return decide(orDefault(flag.OffVariant, VariantOff), ReasonDisabled)
+	}
+	for _, rule := range flag.Rules {
+		if !rule.match(target) {
+			continue
+		}
+		if len(rule.Rollout) == 0 {
+			return decide(rule.Variant, ReasonRule)
+		}
+		if variant, ok := rollout(strings.ToLower(name), target.Key, rule.Rollout); ok {
+			return decide(variant, ReasonRule)
+		}
+	}
+	if variant, ok := rollout(strings.ToLower(name), target.Key, flag.Rollout); ok {
+		return decide(variant, ReasonRollout)
+	}
+	return decide(orDefault(flag.DefaultVariant, VariantOn), ReasonDefault)
+}
+
+// normalize 将变体名转为小写，与 viper 解析后的 Variants 一致
+func (f Flag) normalize() Flag {
+	if f.Variants != nil {
+		variants := make(map[string]any, len(f.Variants))
+		for variant, value := range f.Variants {
+			variants[strings.ToLower(variant)] = value
+		}
+		f.Variants = variants
+	}
+	f.DefaultVariant = strings.ToLower(f.DefaultVariant)
+	f.OffVariant = strings.ToLower(f.OffVariant)
+	f.Rollout = normalizeRollout(f.Rollout)
+	rules := make([]Rule, len(f.Rules))
+	for i, rule := range f.Rules {
+		rule.Variant = strings.ToLower(rule.Variant)
+		rule.Rollout = normalizeRollout(rule.Rollout)
+		rules[i] = rule
+	}
+	f.Rules = rules
+	return f
+}
+
+func normalizeRollout(list []Rollout) []Rollout {
+	if list == nil {
+		return nil
+	}
+	normalized := make([]Rollout, len(list))
+	for i, item := range list {
+		normalized[i] = Rollout{Variant: strings.ToLower(item.Variant), Percent: item.Percent}
+	}
+	return normalized
+}
+
+// value 返回变体对应的值，布尔开关为 true/false
+func (f Flag) value(variant string) any {
+	if len(f.Variants) == 0 {
+		return variant == VariantOn
+	}
+	return f.Variants[variant]
+}
+
+func (r Rule) match(target Target) bool {
+	var val string
+	if r.Attribute == "key" {
+		val = target.Key
+	} else {
+		v, ok := target.Attributes[r.Attribute]
+		if !ok {
+			return r.Operator == "ne" || r.Operator == "not_in"
+		}
+		val = v
+	}
+	switch r.Operator {
+	case "eq", "in", "":
+		return contains(r.Values, val)
+	case "ne", "not_in":
+		return !contains(r.Values, val)
+	case "prefix":
+		return anyOf(r.Values, func(s string) bool { return strings.HasPrefix(val, s) })
+	case "suffix":
+		return anyOf(r.Values, func(s string) bool { return strings.HasSuffix(val, s) })
+	case "contains":
+		return anyOf(r.Values, func(s string) bool { return strings.Contains(val, s) })
+	}
+	return false
+}
+
+// rollout 按 flag 名和用户 key 哈希到 [0, 10000) 的桶，同一用户在同一开关上的结果稳定
+func rollout(name, key string, list []Rollout) (string, bool) {
+	if len(list) == 0 {
+		return "", false
+	}
+	sum := sha1.Sum([]byte(name + ":" + key))
+	bucket := float64(binary.BigEndian.Uint32(sum[:4])%10000) / 100
+	var acc float64
+	for _, item := range list {
+		acc += item.Percent
+		if bucket < acc {
+			return item.Variant, true
+		}
+	}
+	return "", false
+}
+
+func contains(list []string, s string) bool {
+	return anyOf(list, func(item string) bool { return item == s })
+}
+
+func anyOf(list []string, fn func(string) bool) bool {
+	for _, item := range list {
+		if fn(item) {
+			return true
+		}
+	}
+	return false
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
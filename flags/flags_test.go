@@ -0,0 +1,130 @@
+package flags
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// 哈希算法或分桶方式变化会让已灰度的用户换组，这里固定已知用户在 checkout 上的结果
+func TestRolloutStable(t *testing.T) {
+	cases := []struct {
+		key  string
+		in   float64 // 灰度比例不低于 in 时命中
+		last float64 // 灰度比例为 last 时仍不命中
+	}{
+		{"user-1", 75, 50},
+		{"user-2", 75, 50},
+		{"user-3", 50, 25},
+		{"user-4", 10, 0},
+	}
+	for _, c := range cases {
+		for _, percent := range []float64{c.last, c.in} {
+			got, _ := rollout("checkout", c.key, []Rollout{{Variant: "in", Percent: percent}, {Variant: "out", Percent: 100 - percent}})
+			want := "out"
+			if percent >= c.in {
+				want = "in"
+			}
+			if got != want {
+				t.Errorf("rollout(checkout, %s) at %v%% = %s, want %s", c.key, percent, got, want)
+			}
+		}
+	}
+}
+
+func TestRolloutMonotonic(t *testing.T) {
+	// 扩大灰度比例时，已命中的用户不会退出
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		in := false
+		for percent := 0.0; percent <= 100; percent += 5 {
+			got, _ := rollout("checkout", key, []Rollout{{Variant: "in", Percent: percent}})
+			if in && got != "in" {
+				t.Fatalf("%s left the rollout at %v%%", key, percent)
+			}
+			in = got == "in"
+		}
+		if !in {
+			t.Fatalf("%s not in a 100%% rollout", key)
+		}
+	}
+}
+
+func TestRolloutDistribution(t *testing.T) {
+	const n = 20000
+	hits := 0
+	for i := 0; i < n; i++ {
+		if variant, _ := rollout("checkout", fmt.Sprintf("user-%d", i), []Rollout{{Variant: "in", Percent: 30}}); variant == "in" {
+			hits++
+		}
+	}
+	if ratio := float64(hits) / n; math.Abs(ratio-0.3) > 0.02 {
+		t.Errorf("30%% rollout hit %.3f", ratio)
+	}
+}
+
+func TestRuleOrder(t *testing.T) {
+	c := New(Config{"checkout": {
+		Enabled:  true,
+		Variants: map[string]any{"a": 1, "b": 2, "c": 3},
+		Rules: []Rule{
+			{Attribute: "country", Operator: "eq", Values: []string{"cn"}, Variant: "a"},
+			{Attribute: "plan", Operator: "in", Values: []string{"pro"}, Variant: "b"},
+		},
+		DefaultVariant: "c",
+	}})
+	cases := []struct {
+		attrs   map[string]string
+		variant string
+		reason  string
+	}{
+		{map[string]string{"country": "cn", "plan": "pro"}, "a", ReasonRule},
+		{map[string]string{"country": "us", "plan": "pro"}, "b", ReasonRule},
+		{map[string]string{"country": "us"}, "c", ReasonDefault},
+	}
+	for _, tc := range cases {
+		ctx := NewContext(context.Background(), Target{Key: "u", Attributes: tc.attrs})
+		d := c.Evaluate(ctx, "checkout")
+		if d.Variant != tc.variant || d.Reason != tc.reason {
+			t.Errorf("%v: got %s (%s), want %s (%s)", tc.attrs, d.Variant, d.Reason, tc.variant, tc.reason)
+		}
+	}
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	var cfg struct {
+		Flags Config
+	}
+	data := []byte(`
+flags:
+  newCheckout:
+    enabled: true
+  theme:
+    enabled: true
+    variants:
+      Blue: "#00f"
+      Red: "#f00"
+    defaultVariant: Blue
+`)
+	// 与 conf 相同，经过 viper 解析后 key 全部为小写
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	c := New(cfg.Flags)
+	ctx := context.Background()
+	if !c.Enabled(ctx, "newCheckout") {
+		t.Error("newCheckout should be enabled")
+	}
+	if d := c.Evaluate(ctx, "Theme"); d.Variant != "blue" || d.Value != "#00f" {
+		t.Errorf("theme = %s %v, want blue #00f", d.Variant, d.Value)
+	}
+}
@@ -20,25 +20,28 @@ type Config struct {
 
 type Logger struct {
 	*zap.Logger
+	level   *zap.AtomicLevel
+	profile string
 }
 
 type Option func(log *Logger)
 
-func NewLog(cfg *Config, opts ...Option) *Logger {
+// WithProfile 指定运行环境，prod 使用生产模式，其他为开发模式，通常传入 conf.Profile()。
+// 默认读取环境变量 ENV，conf 通过 --profile 或 WithProfile 选择 profile 时会同步写入 ENV，
+// 因此在 conf.Load 之后创建的 Logger 不传该选项也使用相同的 profile
+func WithProfile(profile string) Option {
+	return func(log *Logger) {
+		log.profile = profile
+	}
+}
 
-	var level zapcore.Level //debug<info<warn<error<fatal<panic
-	switch cfg.LogLevel {
-	case "debug":
-		level = zap.DebugLevel
-	case "info":
-		level = zap.InfoLevel
-	case "warn":
-		level = zap.WarnLevel
-	case "error":
-		level = zap.ErrorLevel
-	default:
-		level = zap.InfoLevel
+func NewLog(cfg *Config, opts ...Option) *Logger {
+	l := &Logger{profile: os.Getenv("ENV")}
+	for _, opt := range opts {
+		opt(l)
 	}
+
+	level := zap.NewAtomicLevelAt(parseLevel(cfg.LogLevel))
 	hook := lumberjack.Logger{
 		Filename:   cfg.LogFileName, // Log file path
 		MaxSize:    cfg.MaxSize,     // 每个日志文件的最大单位：M
@@ -84,14 +87,36 @@ func NewLog(cfg *Config, opts ...Option) *Logger {
 		level,
 	)
 
-	val, ex := os.LookupEnv("ENV")
-	if !ex {
-		return &Logger{zap.New(core, zap.Development(), zap.AddCaller(), zap.AddStacktrace(zap.ErrorLevel))}
+	l.level = &level
+	if l.profile != "prod" {
+		l.Logger = zap.New(core, zap.Development(), zap.AddCaller(), zap.AddStacktrace(zap.ErrorLevel))
+	} else {
+		l.Logger = zap.New(core, zap.AddCaller(), zap.AddStacktrace(zap.ErrorLevel))
 	}
-	if val != "prod" {
-		return &Logger{zap.New(core, zap.Development(), zap.AddCaller(), zap.AddStacktrace(zap.ErrorLevel))}
+	return l
+}
+
+// SetLevel 运行时修改日志级别，可直接作为 conf.Value[string] 的 OnChange 回调
+func (l *Logger) SetLevel(level string) {
+	if l.level != nil {
+		l.level.SetLevel(parseLevel(level))
+	}
+}
+
+// parseLevel 解析日志级别，未知级别使用 info
+func parseLevel(level string) zapcore.Level {
+	switch level { //debug<info<warn<error<fatal<panic
+	case "debug":
+		return zap.DebugLevel
+	case "info":
+		return zap.InfoLevel
+	case "warn":
+		return zap.WarnLevel
+	case "error":
+		return zap.ErrorLevel
+	default:
+		return zap.InfoLevel
 	}
-	return &Logger{zap.New(core, zap.AddCaller(), zap.AddStacktrace(zap.ErrorLevel))}
 }
 
 func timeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {